@@ -28,3 +28,17 @@ import (
 
 func main() {{ "ExampleInMem" | code }}
 ```
+
+## Development
+
+Adapters such as `bfss3` or `bfsotel` are separate modules which require a
+released version of the root module. Releases must therefore tag the root
+module first, before the adapters are updated to require the new version.
+
+To work on the root module and adapters together, set up a local (untracked)
+workspace which resolves the root module from the checkout:
+
+```shell
+go work init . ./bfsazure ./bfscompress ./bfsftp ./bfsgs ./bfsotel ./bfss3 ./bfsscp ./bfswebdav
+go work edit -replace github.com/bsm/bfs@v0.13.0=./
+```
@@ -105,6 +105,10 @@ func canonicalize(key string) string {
 type WriteOptions struct {
 	ContentType string
 	Metadata    Metadata
+
+	// ModTime sets an explicit modification time. It is currently only
+	// supported by InMem and ignored by other buckets.
+	ModTime time.Time
 }
 
 // GetContentType returns a content type.
@@ -115,6 +119,14 @@ func (o *WriteOptions) GetContentType() string {
 	return ""
 }
 
+// GetModTime returns the modification time.
+func (o *WriteOptions) GetModTime() time.Time {
+	if o != nil {
+		return o.ModTime
+	}
+	return time.Time{}
+}
+
 // GetMetadata returns a content type.
 func (o *WriteOptions) GetMetadata() Metadata {
 	if o != nil {
@@ -174,6 +186,14 @@ type Resolver func(context.Context, *url.URL) (Bucket, error)
 //	...
 //	bucket, err := bfs.Resolve(context.TODO(), u)
 //	...
+//
+// Resolve supports the following query parameters for all schemes:
+//
+//	retry_attempts    - enables retries of failed operations, see WithRetry
+//	retry_backoff     - initial backoff between retries, e.g. 100ms
+//	retry_max_backoff - maximum backoff between retries, e.g. 5s
+//	readonly          - rejects all modifications, see ReadOnly
+//	writeonce         - refuses to overwrite existing objects, see WriteOnce
 func Resolve(ctx context.Context, u *url.URL) (Bucket, error) {
 	registryLock.Lock()
 	resv, ok := registry[u.Scheme]
@@ -182,7 +202,41 @@ func Resolve(ctx context.Context, u *url.URL) (Bucket, error) {
 		return nil, fmt.Errorf("bfs: unknown URL scheme %q", u.Scheme)
 	}
 
-	return resv(ctx, u)
+	query := u.Query()
+	policy, err := parseRetryPolicy(query)
+	if err != nil {
+		return nil, err
+	}
+	readOnly, err := parseFlag(query, "readonly")
+	if err != nil {
+		return nil, err
+	}
+	writeOnce, err := parseFlag(query, "writeonce")
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil && !readOnly && !writeOnce {
+		return resv(ctx, u)
+	}
+
+	// strip wrapper parameters before passing on the URL
+	u2 := *u
+	u2.RawQuery = query.Encode()
+	bucket, err := resv(ctx, &u2)
+	if err != nil {
+		return nil, err
+	}
+	// apply guards outside of retries, which only create on commit
+	if policy != nil {
+		bucket = WithRetry(bucket, policy)
+	}
+	if writeOnce {
+		bucket = WriteOnce(bucket)
+	}
+	if readOnly {
+		bucket = ReadOnly(bucket)
+	}
+	return bucket, nil
 }
 
 // Connect connects to a bucket via URL. Example (from bfs/bfsfs):
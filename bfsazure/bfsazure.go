@@ -0,0 +1,545 @@
+// Package bfsazure abstracts Azure Blob Storage containers.
+//
+// When imported, it registers a global `azblob://` scheme resolver and can be used like:
+//
+//	import (
+//	  "github.com/bsm/bfs"
+//
+//	  _ "github.com/bsm/bfs/bfsazure"
+//	)
+//
+//	func main() {
+//	  ctx := context.TODO()
+//	  b, _ := bfs.Connect(ctx, "azblob://container/a?account_name=ACCOUNT&account_key=KEY")
+//	  f, _ := b.Open(ctx, "b/c.txt") // opens azblob://container/a/b/c.txt
+//	  ...
+//	}
+//
+// bfs.Connect supports the following query parameters:
+//
+//	account_name      - storage account name, defaults to AZURE_STORAGE_ACCOUNT
+//	account_key       - storage account key, defaults to AZURE_STORAGE_KEY
+//	connection_string - a full connection string, defaults to AZURE_STORAGE_CONNECTION_STRING
+//	endpoint          - custom service URL, e.g. for the Azurite emulator
+//	prefix            - an optional path prefix (if not specified via the URL path)
+package bfsazure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/bsm/bfs"
+	"github.com/bsm/bfs/internal"
+)
+
+func init() {
+	bfs.Register("azblob", func(ctx context.Context, u *url.URL) (bfs.Bucket, error) {
+		query := u.Query()
+
+		prefix := u.Path
+		if prefix == "" {
+			prefix = query.Get("prefix")
+		}
+
+		return New(ctx, u.Host, &Config{
+			AccountName:      query.Get("account_name"),
+			AccountKey:       query.Get("account_key"),
+			ConnectionString: query.Get("connection_string"),
+			ServiceURL:       query.Get("endpoint"),
+			Prefix:           prefix,
+		})
+	})
+}
+
+// Config is passed to New to configure the Azure Blob Storage connection.
+type Config struct {
+	// Storage account name, defaults to AZURE_STORAGE_ACCOUNT env variable.
+	AccountName string
+	// Storage account key, defaults to AZURE_STORAGE_KEY env variable.
+	AccountKey string
+	// Connection string, defaults to AZURE_STORAGE_CONNECTION_STRING
+	// env variable. Takes precedence over AccountName/AccountKey.
+	ConnectionString string
+	// Token credential, used instead of a shared key when set.
+	Credential azcore.TokenCredential
+	// Custom service URL, defaults to https://ACCOUNT.blob.core.windows.net/.
+	ServiceURL string
+	// Native client options.
+	Options *azblob.ClientOptions
+	// An optional path prefix.
+	Prefix string
+}
+
+func (c *Config) norm() error {
+	if c.ConnectionString == "" && c.AccountName == "" && c.Credential == nil {
+		c.ConnectionString = os.Getenv("AZURE_STORAGE_CONNECTION_STRING")
+	}
+	if c.AccountName == "" {
+		c.AccountName = os.Getenv("AZURE_STORAGE_ACCOUNT")
+	}
+	if c.AccountKey == "" {
+		c.AccountKey = os.Getenv("AZURE_STORAGE_KEY")
+	}
+	if c.ServiceURL == "" && c.AccountName != "" {
+		c.ServiceURL = "https://" + c.AccountName + ".blob.core.windows.net/"
+	}
+
+	c.Prefix = strings.TrimLeft(c.Prefix, "/")
+	if c.Prefix != "" && !strings.HasSuffix(c.Prefix, "/") {
+		c.Prefix = c.Prefix + "/"
+	}
+	return nil
+}
+
+func (c *Config) newClient() (*azblob.Client, error) {
+	switch {
+	case c.ConnectionString != "":
+		return azblob.NewClientFromConnectionString(c.ConnectionString, c.Options)
+	case c.ServiceURL == "":
+		return nil, errors.New("bfsazure: missing account name or service URL")
+	case c.Credential != nil:
+		return azblob.NewClient(c.ServiceURL, c.Credential, c.Options)
+	case c.AccountKey != "":
+		cred, err := azblob.NewSharedKeyCredential(c.AccountName, c.AccountKey)
+		if err != nil {
+			return nil, err
+		}
+		return azblob.NewClientWithSharedKeyCredential(c.ServiceURL, cred, c.Options)
+	default:
+		return azblob.NewClientWithNoCredential(c.ServiceURL, c.Options)
+	}
+}
+
+type bucket struct {
+	container *container.Client
+	config    *Config
+}
+
+// New initiates an bfs.Bucket backed by an Azure Blob Storage container.
+func New(_ context.Context, name string, cfg *Config) (bfs.Bucket, error) {
+	config := new(Config)
+	if cfg != nil {
+		*config = *cfg
+	}
+	if err := config.norm(); err != nil {
+		return nil, err
+	}
+
+	client, err := config.newClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return &bucket{
+		container: client.ServiceClient().NewContainerClient(name),
+		config:    config,
+	}, nil
+}
+
+func (b *bucket) stripPrefix(name string) string {
+	if b.config.Prefix != "" {
+		name = strings.TrimPrefix(name, b.config.Prefix)
+	}
+	return strings.TrimLeft(name, "/")
+}
+
+func (b *bucket) withPrefix(name string) string {
+	if b.config.Prefix != "" {
+		name = internal.WithinNamespace(b.config.Prefix, name)
+	}
+	return strings.TrimLeft(name, "/")
+}
+
+// Glob implements bfs.Bucket.
+func (b *bucket) Glob(ctx context.Context, pattern string) (bfs.Iterator, error) {
+	// quick sanity check
+	if _, err := doublestar.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	iter := &iterator{
+		ctx:     ctx,
+		bucket:  b,
+		pattern: pattern,
+		pos:     -1,
+	}
+	if pattern == "" {
+		return iter, nil
+	}
+
+	dir, _ := doublestar.SplitPattern(pattern)
+	if dir == "." {
+		dir = ""
+	} else {
+		dir += "/"
+	}
+	iter.prefixes = []string{b.config.Prefix + dir}
+	return iter, nil
+}
+
+// Head implements bfs.Bucket.
+func (b *bucket) Head(ctx context.Context, name string) (*bfs.MetaInfo, error) {
+	resp, err := b.container.NewBlobClient(b.withPrefix(name)).GetProperties(ctx, nil)
+	if err != nil {
+		return nil, normError(err)
+	}
+
+	return &bfs.MetaInfo{
+		Name:        name,
+		Size:        deref(resp.ContentLength),
+		ModTime:     deref(resp.LastModified),
+		ContentType: deref(resp.ContentType),
+		Metadata:    decodeMetadata(resp.Metadata),
+	}, nil
+}
+
+// Open implements bfs.Bucket.
+func (b *bucket) Open(ctx context.Context, name string) (bfs.Reader, error) {
+	resp, err := b.container.NewBlobClient(b.withPrefix(name)).DownloadStream(ctx, nil)
+	if err != nil {
+		return nil, normError(err)
+	}
+	return &response{
+		ReadCloser:    resp.Body,
+		ContentLength: deref(resp.ContentLength),
+	}, nil
+}
+
+// Create implements bfs.Bucket.
+func (b *bucket) Create(ctx context.Context, name string, opts *bfs.WriteOptions) (bfs.Writer, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	pr, pw := io.Pipe()
+
+	w := &writer{
+		ctx:    ctx,
+		cancel: cancel,
+		pw:     pw,
+		done:   make(chan struct{}),
+	}
+
+	var headers *blob.HTTPHeaders
+	if ct := opts.GetContentType(); ct != "" {
+		headers = &blob.HTTPHeaders{BlobContentType: to.Ptr(ct)}
+	}
+
+	client := b.container.NewBlockBlobClient(b.withPrefix(name))
+	go func() {
+		defer close(w.done)
+
+		_, err := client.UploadStream(ctx, pr, &blockblob.UploadStreamOptions{
+			HTTPHeaders: headers,
+			Metadata:    encodeMetadata(opts.GetMetadata()),
+		})
+		w.err = normError(err)
+		_ = pr.CloseWithError(err)
+	}()
+	return w, nil
+}
+
+// Remove implements bfs.Bucket.
+func (b *bucket) Remove(ctx context.Context, name string) error {
+	_, err := b.container.NewBlobClient(b.withPrefix(name)).Delete(ctx, nil)
+	if err = normError(err); errors.Is(err, bfs.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Copy supports copying of objects within the bucket.
+func (b *bucket) Copy(ctx context.Context, src, dst string) error {
+	source := b.container.NewBlobClient(b.withPrefix(src))
+	target := b.container.NewBlobClient(b.withPrefix(dst))
+
+	resp, err := target.StartCopyFromURL(ctx, source.URL(), nil)
+	if err != nil {
+		return normError(err)
+	}
+
+	// server-side copies are asynchronous, wait for completion
+	status := deref(resp.CopyStatus)
+	for delay := 10 * time.Millisecond; status == blob.CopyStatusTypePending; delay = min(2*delay, time.Second) {
+		select {
+		case <-ctx.Done():
+			_, _ = target.AbortCopyFromURL(context.Background(), deref(resp.CopyID), nil)
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		props, err := target.GetProperties(ctx, nil)
+		if err != nil {
+			return normError(err)
+		}
+		status = deref(props.CopyStatus)
+	}
+
+	if status != blob.CopyStatusTypeSuccess {
+		return fmt.Errorf("bfsazure: copy %s", status)
+	}
+	return nil
+}
+
+// Close implements bfs.Bucket.
+func (*bucket) Close() error { return nil }
+
+// --------------------------------------------------------
+
+func normError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if bloberror.HasCode(err, bloberror.BlobNotFound, bloberror.ContainerNotFound, bloberror.ResourceNotFound) {
+		return bfs.ErrNotFound
+	}
+	if respErr := new(azcore.ResponseError); errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound {
+		return bfs.ErrNotFound
+	}
+	return err
+}
+
+// encodeMetadata converts metadata into Azure's format. Azure only accepts
+// metadata keys that are valid C# identifiers, dashes are therefore
+// replaced with underscores.
+func encodeMetadata(meta bfs.Metadata) map[string]*string {
+	if len(meta) == 0 {
+		return nil
+	}
+
+	enc := make(map[string]*string, len(meta))
+	for k, v := range meta {
+		enc[strings.ReplaceAll(k, "-", "_")] = to.Ptr(v)
+	}
+	return enc
+}
+
+// decodeMetadata converts Azure metadata into bfs.Metadata.
+func decodeMetadata(meta map[string]*string) bfs.Metadata {
+	dec := make(map[string]string, len(meta))
+	for k, v := range meta {
+		dec[k] = deref(v)
+	}
+	return bfs.NormMetadata(dec)
+}
+
+// --------------------------------------------------------
+
+type writer struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	pw     *io.PipeWriter
+
+	done chan struct{}
+	err  error // set by the upload, once done
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+func (w *writer) Discard() error {
+	err := w.ctx.Err()
+	w.cancel()
+	_ = w.pw.CloseWithError(context.Canceled)
+	<-w.done
+
+	if err == nil {
+		return nil
+	}
+	return context.Canceled
+}
+
+func (w *writer) Commit() error {
+	if err := w.ctx.Err(); err != nil {
+		w.cancel()
+		_ = w.pw.CloseWithError(err)
+		<-w.done
+		return err
+	}
+
+	_ = w.pw.Close()
+	<-w.done
+	w.cancel()
+	return w.err
+}
+
+type response struct {
+	io.ReadCloser
+	ContentLength int64
+}
+
+func (r *response) Read(p []byte) (n int, err error) {
+	if r.ContentLength <= 0 {
+		return 0, io.EOF
+	}
+
+	if int64(len(p)) > r.ContentLength {
+		p = p[:r.ContentLength]
+	}
+	n, err = r.ReadCloser.Read(p)
+	if err == io.EOF && n > 0 && int64(n) == r.ContentLength {
+		err = nil
+	}
+	r.ContentLength -= int64(n)
+	return
+}
+
+// --------------------------------------------------------------------
+
+type iterator struct {
+	ctx context.Context
+
+	bucket   *bucket
+	pattern  string
+	prefixes []string // pending prefixes, relative to the container
+
+	err  error
+	pos  int
+	page []object
+}
+
+type object struct {
+	key     string
+	size    int64
+	modTime time.Time
+}
+
+func (i *iterator) Close() error {
+	i.prefixes = i.prefixes[:0]
+	i.pos = len(i.page)
+	return nil
+}
+
+func (i *iterator) Name() string {
+	if i.pos > -1 && i.pos < len(i.page) {
+		return i.page[i.pos].key
+	}
+	return ""
+}
+
+func (i *iterator) Size() int64 {
+	if i.pos > -1 && i.pos < len(i.page) {
+		return i.page[i.pos].size
+	}
+	return 0
+}
+
+func (i *iterator) ModTime() time.Time {
+	if i.pos > -1 && i.pos < len(i.page) {
+		return i.page[i.pos].modTime
+	}
+	return time.Time{}
+}
+
+func (i *iterator) Next() bool {
+	if i.err != nil {
+		return false
+	}
+
+	if i.pos++; i.pos < len(i.page) {
+		return true
+	}
+
+	if len(i.prefixes) == 0 {
+		return false
+	}
+
+	// pop last prefix
+	tail := i.prefixes[len(i.prefixes)-1]
+	i.prefixes = i.prefixes[:len(i.prefixes)-1]
+
+	if err := i.fetchPrefix(tail); err != nil {
+		i.err = err
+		return false
+	}
+	return i.Next()
+}
+
+func (i *iterator) Error() error { return i.err }
+
+// fetchPrefix lists a single level of the blob hierarchy.
+func (i *iterator) fetchPrefix(prefix string) error {
+	i.page = i.page[:0]
+	i.pos = -1
+
+	pager := i.bucket.container.NewListBlobsHierarchyPager("/", &container.ListBlobsHierarchyOptions{
+		Prefix: to.Ptr(prefix),
+	})
+	for pager.More() {
+		res, err := pager.NextPage(i.ctx)
+		if err != nil {
+			return normError(err)
+		}
+		if res.Segment == nil {
+			continue
+		}
+
+		for _, pfx := range res.Segment.BlobPrefixes {
+			name := deref(pfx.Name)
+			if matchDir(i.pattern, strings.TrimSuffix(i.bucket.stripPrefix(name), "/")) {
+				i.prefixes = append(i.prefixes, name)
+			}
+		}
+
+		for _, item := range res.Segment.BlobItems {
+			name := i.bucket.stripPrefix(deref(item.Name))
+			if ok, err := doublestar.Match(i.pattern, name); err != nil {
+				return err
+			} else if !ok {
+				continue
+			}
+
+			obj := object{key: name}
+			if props := item.Properties; props != nil {
+				obj.size = deref(props.ContentLength)
+				obj.modTime = deref(props.LastModified)
+			}
+			i.page = append(i.page, obj)
+		}
+	}
+	return nil
+}
+
+// matchDir reports whether objects within dir could possibly match
+// the pattern.
+func matchDir(pattern, dir string) bool {
+	if strings.ContainsRune(pattern, '{') {
+		return true // alternatives may span multiple segments
+	}
+
+	segs := strings.Split(pattern, "/")
+	for n, part := range strings.Split(dir, "/") {
+		if n < len(segs) && strings.Contains(segs[n], "**") {
+			return true
+		}
+		if n >= len(segs)-1 {
+			return false // pattern is not deep enough
+		}
+		if ok, _ := doublestar.Match(segs[n], part); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func deref[T any](v *T) T {
+	if v != nil {
+		return *v
+	}
+	var zero T
+	return zero
+}
@@ -0,0 +1,62 @@
+package bfsazure_test
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/bsm/bfs/bfsazure"
+	"github.com/bsm/bfs/bfstest"
+)
+
+// Well-known Azurite development storage credentials.
+const (
+	accountName = "devstoreaccount1"
+	accountKey  = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+)
+
+// Test runs against the service URL specified via BFSAZURE_ENDPOINT
+// (e.g. http://127.0.0.1:10000/devstoreaccount1 for Azurite) and falls back
+// on a minimal in-process emulator.
+func Test(t *testing.T) {
+	endpoint := os.Getenv("BFSAZURE_ENDPOINT")
+	if endpoint == "" {
+		server := httptest.NewServer(newEmulator())
+		defer server.Close()
+
+		endpoint = server.URL + "/" + accountName
+	}
+
+	cred, err := azblob.NewSharedKeyCredential(accountName, accountKey)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	client, err := azblob.NewClientWithSharedKeyCredential(endpoint, cred, nil)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if _, err := client.CreateContainer(t.Context(), "bfs-azure-test", nil); err != nil && !bloberror.HasCode(err, bloberror.ContainerAlreadyExists) {
+		t.Fatal("Unexpected error", err)
+	}
+
+	bucket, err := bfsazure.New(t.Context(), "bfs-azure-test", &bfsazure.Config{
+		AccountName: accountName,
+		AccountKey:  accountKey,
+		ServiceURL:  endpoint,
+		Prefix:      "x/y",
+	})
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	defer bucket.Close()
+
+	t.Run("common", func(t *testing.T) {
+		bfstest.Common(t, bucket, bfstest.Supports{ContentType: true, Metadata: true})
+	})
+
+	t.Run("slow", func(t *testing.T) {
+		bfstest.Slow(t, bucket, bfstest.Supports{ContentType: true, Metadata: true})
+	})
+}
@@ -0,0 +1,263 @@
+package bfsazure_test
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// emulator is a minimal, in-memory stand-in for the Azure Blob Storage REST
+// API. It supports just enough for the lint suite and ignores authentication.
+type emulator struct {
+	containers map[string]map[string]*emuBlob
+	staged     map[string][]byte
+	mu         sync.Mutex
+}
+
+type emuBlob struct {
+	data        []byte
+	contentType string
+	metadata    http.Header
+	modTime     time.Time
+}
+
+func newEmulator() *emulator {
+	return &emulator{
+		containers: make(map[string]map[string]*emuBlob),
+		staged:     make(map[string][]byte),
+	}
+}
+
+func (e *emulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// path: /account/container[/blob]
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 3)
+	if len(parts) < 2 {
+		emuError(w, http.StatusBadRequest, "InvalidUri")
+		return
+	}
+
+	query := r.URL.Query()
+	if len(parts) == 2 || parts[2] == "" {
+		e.serveContainer(w, r, parts[1], query)
+		return
+	}
+
+	blobs, ok := e.containers[parts[1]]
+	if !ok {
+		emuError(w, http.StatusNotFound, "ContainerNotFound")
+		return
+	}
+	e.serveBlob(w, r, blobs, parts[1], parts[2], query)
+}
+
+func (e *emulator) serveContainer(w http.ResponseWriter, r *http.Request, name string, query map[string][]string) {
+	get := func(k string) string {
+		if v := query[k]; len(v) != 0 {
+			return v[0]
+		}
+		return ""
+	}
+
+	switch {
+	case r.Method == http.MethodPut && get("restype") == "container":
+		if _, ok := e.containers[name]; ok {
+			emuError(w, http.StatusConflict, "ContainerAlreadyExists")
+			return
+		}
+		e.containers[name] = make(map[string]*emuBlob)
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodGet && get("comp") == "list":
+		blobs, ok := e.containers[name]
+		if !ok {
+			emuError(w, http.StatusNotFound, "ContainerNotFound")
+			return
+		}
+		e.list(w, name, blobs, get("prefix"), get("delimiter"))
+	default:
+		emuError(w, http.StatusBadRequest, "UnsupportedHttpVerb")
+	}
+}
+
+func (e *emulator) list(w http.ResponseWriter, container string, blobs map[string]*emuBlob, prefix, delimiter string) {
+	type properties struct {
+		LastModified  string `xml:"Last-Modified"`
+		ContentLength int64  `xml:"Content-Length"`
+		ContentType   string `xml:"Content-Type,omitempty"`
+		BlobType      string `xml:"BlobType"`
+	}
+	type blobItem struct {
+		XMLName    xml.Name   `xml:"Blob"`
+		Name       string     `xml:"Name"`
+		Properties properties `xml:"Properties"`
+	}
+	type blobPrefix struct {
+		XMLName xml.Name `xml:"BlobPrefix"`
+		Name    string   `xml:"Name"`
+	}
+	type results struct {
+		XMLName       xml.Name `xml:"EnumerationResults"`
+		ContainerName string   `xml:"ContainerName,attr"`
+		Prefix        string   `xml:"Prefix"`
+		Delimiter     string   `xml:"Delimiter,omitempty"`
+		Blobs         struct {
+			Items []any
+		} `xml:"Blobs"`
+		NextMarker string `xml:"NextMarker"`
+	}
+
+	names := make([]string, 0, len(blobs))
+	for name := range blobs {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	res := results{ContainerName: container, Prefix: prefix, Delimiter: delimiter}
+	seen := make(map[string]struct{})
+	for _, name := range names {
+		if delimiter != "" {
+			if n := strings.Index(name[len(prefix):], delimiter); n > -1 {
+				pfx := name[:len(prefix)+n+len(delimiter)]
+				if _, ok := seen[pfx]; !ok {
+					seen[pfx] = struct{}{}
+					res.Blobs.Items = append(res.Blobs.Items, blobPrefix{Name: pfx})
+				}
+				continue
+			}
+		}
+
+		blob := blobs[name]
+		res.Blobs.Items = append(res.Blobs.Items, blobItem{Name: name, Properties: properties{
+			LastModified:  blob.modTime.Format(http.TimeFormat),
+			ContentLength: int64(len(blob.data)),
+			ContentType:   blob.contentType,
+			BlobType:      "BlockBlob",
+		}})
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	_, _ = io.WriteString(w, xml.Header)
+	_ = xml.NewEncoder(w).Encode(res)
+}
+
+func (e *emulator) serveBlob(w http.ResponseWriter, r *http.Request, blobs map[string]*emuBlob, container, name string, query map[string][]string) {
+	comp := ""
+	if v := query["comp"]; len(v) != 0 {
+		comp = v[0]
+	}
+
+	switch {
+	case r.Method == http.MethodPut && comp == "block":
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			emuError(w, http.StatusBadRequest, "InvalidInput")
+			return
+		}
+		e.staged[container+"/"+name+"/"+query["blockid"][0]] = data
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && comp == "blocklist":
+		var list struct {
+			IDs []string `xml:",any"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&list); err != nil {
+			emuError(w, http.StatusBadRequest, "InvalidXmlDocument")
+			return
+		}
+
+		var data []byte
+		for _, id := range list.IDs {
+			data = append(data, e.staged[container+"/"+name+"/"+id]...)
+			delete(e.staged, container+"/"+name+"/"+id)
+		}
+		blobs[name] = newEmuBlob(r, data)
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && r.Header.Get("X-Ms-Copy-Source") != "":
+		src := r.Header.Get("X-Ms-Copy-Source")
+		if u, err := url.Parse(src); err == nil {
+			src = u.Path
+		}
+		if i := strings.Index(src, "/"+container+"/"); i > -1 {
+			src = src[i+len(container)+2:]
+		}
+		srcBlob, ok := blobs[src]
+		if !ok {
+			emuError(w, http.StatusNotFound, "CannotVerifyCopySource")
+			return
+		}
+		blob := *srcBlob
+		blob.modTime = time.Now()
+		blobs[name] = &blob
+		w.Header().Set("X-Ms-Copy-Id", strconv.FormatInt(time.Now().UnixNano(), 10))
+		w.Header().Set("X-Ms-Copy-Status", "success")
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			emuError(w, http.StatusBadRequest, "InvalidInput")
+			return
+		}
+		blobs[name] = newEmuBlob(r, data)
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		blob, ok := blobs[name]
+		if !ok {
+			emuError(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+
+		for k, v := range blob.metadata {
+			w.Header()[k] = v
+		}
+		if blob.contentType != "" {
+			w.Header().Set("Content-Type", blob.contentType)
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(blob.data)))
+		w.Header().Set("Last-Modified", blob.modTime.Format(http.TimeFormat))
+		w.Header().Set("X-Ms-Blob-Type", "BlockBlob")
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(blob.data)
+		}
+	case r.Method == http.MethodDelete:
+		if _, ok := blobs[name]; !ok {
+			emuError(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		delete(blobs, name)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		emuError(w, http.StatusBadRequest, "UnsupportedHttpVerb")
+	}
+}
+
+func newEmuBlob(r *http.Request, data []byte) *emuBlob {
+	blob := &emuBlob{
+		data:        data,
+		contentType: r.Header.Get("X-Ms-Blob-Content-Type"),
+		metadata:    make(http.Header),
+		modTime:     time.Now(),
+	}
+	for k, v := range r.Header {
+		if strings.HasPrefix(k, "X-Ms-Meta-") {
+			blob.metadata[k] = v
+		}
+	}
+	return blob
+}
+
+func emuError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("X-Ms-Error-Code", code)
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, xml.Header+"<Error><Code>"+code+"</Code><Message>"+code+"</Message></Error>")
+}
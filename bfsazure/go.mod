@@ -0,0 +1,16 @@
+module github.com/bsm/bfs/bfsazure
+
+go 1.25
+
+require (
+	github.com/Azure/azure-sdk-for-go/sdk/azcore v1.19.1
+	github.com/Azure/azure-sdk-for-go/sdk/storage/azblob v1.6.3
+	github.com/bmatcuk/doublestar/v4 v4.9.1
+	github.com/bsm/bfs v0.13.0
+)
+
+require (
+	github.com/Azure/azure-sdk-for-go/sdk/internal v1.11.2 // indirect
+	golang.org/x/net v0.43.0 // indirect
+	golang.org/x/text v0.28.0 // indirect
+)
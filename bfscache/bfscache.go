@@ -0,0 +1,479 @@
+// Package bfscache implements a read-through cache which stores objects on
+// local disk.
+//
+// Cached objects are keyed by name and version, which is derived from the
+// object's modification time and size. Every Open validates the cache entry
+// with a Head call to the underlying bucket. Please note that rewrites which
+// keep the size and fall within the modification time granularity of the
+// underlying bucket (e.g. one second or one minute on FTP and SFTP servers)
+// cannot be detected and may cause stale data to be served. Concurrent Open
+// calls for the same, uncached object are collapsed into a single download.
+// The total size of the cache is bounded, least recently used entries are
+// evicted first.
+//
+//	import (
+//	  "github.com/bsm/bfs"
+//	  "github.com/bsm/bfs/bfscache"
+//	)
+//
+//	func main() {
+//	  ctx := context.TODO()
+//	  b, _ := bfs.Connect(ctx, "s3://bucket/a")
+//	  b, _ = bfscache.New(b, "/var/cache/bfs", &bfscache.Config{MaxSize: 10 << 30})
+//	  f, _ := b.Open(ctx, "reference.csv")
+//	  ...
+//	}
+package bfscache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bsm/bfs"
+	"github.com/bsm/bfs/bfsfs"
+)
+
+// Config is passed to New to configure the cache.
+type Config struct {
+	// MaxSize is the maximum total size of the cache in bytes.
+	// Default: 1GiB.
+	MaxSize int64
+	// Populate populates the cache with objects written through
+	// the cache. By default, written objects are only invalidated.
+	Populate bool
+	// TempDir is used to spool written objects when Populate is enabled
+	// and by the local file system store. Default: os.TempDir().
+	TempDir string
+}
+
+func (c *Config) norm() {
+	if c.MaxSize <= 0 {
+		c.MaxSize = 1 << 30
+	}
+}
+
+// bucket wraps a bucket and caches objects.
+type bucket struct {
+	bfs.Bucket
+
+	local  bfs.Bucket
+	config *Config
+
+	lru      *list.List // of *entry, most recently used first
+	entries  map[string]*list.Element
+	size     int64
+	inflight map[string]*download
+	mu       sync.Mutex
+}
+
+type entry struct {
+	key  string
+	size int64
+}
+
+type download struct {
+	done chan struct{}
+	err  error
+}
+
+// New wraps a bucket with a local disk cache, stored in dir. The directory
+// is owned by the cache, existing entries are re-used.
+func New(base bfs.Bucket, dir string, cfg *Config) (bfs.Bucket, error) {
+	config := new(Config)
+	if cfg != nil {
+		*config = *cfg
+	}
+	config.norm()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	local, err := bfsfs.New(dir, config.TempDir)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &bucket{
+		Bucket:   base,
+		local:    local,
+		config:   config,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+		inflight: make(map[string]*download),
+	}
+	if err := b.load(context.Background()); err != nil {
+		_ = local.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// Open implements bfs.Bucket.
+func (b *bucket) Open(ctx context.Context, name string) (bfs.Reader, error) {
+	info, err := b.Bucket.Head(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// bypass cache for objects which are too large
+	if info.Size > b.config.MaxSize {
+		return b.Bucket.Open(ctx, name)
+	}
+
+	key := cacheKey(name, info)
+	for attempt := 0; attempt < 3; attempt++ {
+		if r, err := b.openLocal(ctx, key); err == nil {
+			return r, nil
+		} else if !errors.Is(err, bfs.ErrNotFound) {
+			return nil, err
+		}
+
+		if err := b.fetch(ctx, name, key); err != nil && !errors.Is(err, errRetry) {
+			return nil, err
+		}
+	}
+
+	// entry was evicted concurrently, read directly
+	return b.Bucket.Open(ctx, name)
+}
+
+// Create implements bfs.Bucket.
+func (b *bucket) Create(ctx context.Context, name string, opts *bfs.WriteOptions) (bfs.Writer, error) {
+	w, err := b.Bucket.Create(ctx, name, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	cw := &writer{Writer: w, bucket: b, ctx: ctx, name: name}
+	if b.config.Populate {
+		if cw.spool, err = os.CreateTemp(b.config.TempDir, "bfs-cache"); err != nil {
+			_ = w.Discard()
+			return nil, err
+		}
+	}
+	return cw, nil
+}
+
+// Remove implements bfs.Bucket.
+func (b *bucket) Remove(ctx context.Context, name string) error {
+	if err := b.Bucket.Remove(ctx, name); err != nil {
+		return err
+	}
+	return b.invalidate(ctx, name)
+}
+
+// Copy supports the optional Copy extension.
+func (b *bucket) Copy(ctx context.Context, src, dst string) error {
+	copier, ok := b.Bucket.(interface {
+		Copy(context.Context, string, string) error
+	})
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	if err := copier.Copy(ctx, src, dst); err != nil {
+		return err
+	}
+	return b.invalidate(ctx, dst)
+}
+
+// RemoveAll supports the optional RemoveAll extension. Cache entries of
+// removed objects are not invalidated immediately but are never served and
+// eventually evicted.
+func (b *bucket) RemoveAll(ctx context.Context, pattern string) error {
+	remover, ok := b.Bucket.(interface {
+		RemoveAll(context.Context, string) error
+	})
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	return remover.RemoveAll(ctx, pattern)
+}
+
+// Close implements bfs.Bucket.
+func (b *bucket) Close() error {
+	err := b.Bucket.Close()
+	if err2 := b.local.Close(); err2 != nil && err == nil {
+		err = err2
+	}
+	return err
+}
+
+// load populates the index with existing entries.
+func (b *bucket) load(ctx context.Context) error {
+	iter, err := b.local.Glob(ctx, "*/*/*")
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+
+	type localEntry struct {
+		entry
+		modTime time.Time
+	}
+
+	var existing []localEntry
+	for iter.Next() {
+		existing = append(existing, localEntry{
+			entry:   entry{key: iter.Name(), size: iter.Size()},
+			modTime: iter.ModTime(),
+		})
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	// most recently modified first
+	slices.SortFunc(existing, func(a, b localEntry) int {
+		return b.modTime.Compare(a.modTime)
+	})
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, e := range existing {
+		b.entries[e.key] = b.lru.PushBack(&entry{key: e.key, size: e.size})
+		b.size += e.size
+	}
+	return b.evict(ctx)
+}
+
+// openLocal opens a cached entry.
+func (b *bucket) openLocal(ctx context.Context, key string) (bfs.Reader, error) {
+	b.mu.Lock()
+	elem, ok := b.entries[key]
+	if ok {
+		b.lru.MoveToFront(elem)
+	}
+	b.mu.Unlock()
+
+	if !ok {
+		return nil, bfs.ErrNotFound
+	}
+
+	r, err := b.local.Open(ctx, key)
+	if errors.Is(err, bfs.ErrNotFound) {
+		// removed externally
+		b.mu.Lock()
+		b.drop(key)
+		b.mu.Unlock()
+	}
+	return r, err
+}
+
+// errRetry is returned by fetch if another download of the same key has been
+// awaited, or if it was cancelled by the context of its caller.
+var errRetry = errors.New("bfscache: retry")
+
+// fetch downloads an object into the cache, unless another download of the
+// same key is already in progress.
+func (b *bucket) fetch(ctx context.Context, name, key string) error {
+	b.mu.Lock()
+	if dl, ok := b.inflight[key]; ok {
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-dl.done:
+		}
+		if dl.err != nil && !errors.Is(dl.err, context.Canceled) && !errors.Is(dl.err, context.DeadlineExceeded) {
+			return dl.err
+		}
+		return errRetry
+	}
+
+	dl := &download{done: make(chan struct{})}
+	b.inflight[key] = dl
+	b.mu.Unlock()
+
+	dl.err = b.download(ctx, name, key)
+
+	b.mu.Lock()
+	delete(b.inflight, key)
+	b.mu.Unlock()
+	close(dl.done)
+
+	return dl.err
+}
+
+// download copies an object into the local store.
+func (b *bucket) download(ctx context.Context, name, key string) error {
+	r, err := b.Bucket.Open(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return b.store(ctx, key, r)
+}
+
+// store writes an entry to the local store and adds it to the index.
+func (b *bucket) store(ctx context.Context, key string, r io.Reader) error {
+	w, err := b.local.Create(ctx, key, nil)
+	if err != nil {
+		return err
+	}
+	defer w.Discard()
+
+	size, err := io.Copy(w, r)
+	if err != nil {
+		return err
+	}
+	if err := w.Commit(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.drop(key)
+	b.entries[key] = b.lru.PushFront(&entry{key: key, size: size})
+	b.size += size
+	return b.evict(ctx)
+}
+
+// invalidate removes all cached versions of an object.
+func (b *bucket) invalidate(ctx context.Context, name string) error {
+	prefix := cacheDir(name) + "/"
+
+	b.mu.Lock()
+	for key := range b.entries {
+		if strings.HasPrefix(key, prefix) {
+			b.drop(key)
+			if err := b.local.Remove(ctx, key); err != nil {
+				b.mu.Unlock()
+				return err
+			}
+		}
+	}
+	b.mu.Unlock()
+	return nil
+}
+
+// evict removes least recently used entries until the
+// cache size is within bounds. It must be called with
+// mu held.
+func (b *bucket) evict(ctx context.Context) error {
+	for b.size > b.config.MaxSize {
+		elem := b.lru.Back()
+		if elem == nil {
+			break
+		}
+
+		key := elem.Value.(*entry).key
+		b.drop(key)
+		if err := b.local.Remove(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// drop removes an entry from the index. It must be called with
+// mu held.
+func (b *bucket) drop(key string) {
+	if elem, ok := b.entries[key]; ok {
+		b.size -= elem.Value.(*entry).size
+		b.lru.Remove(elem)
+		delete(b.entries, key)
+	}
+}
+
+// --------------------------------------------------------------------
+
+// cacheDir returns the directory of all cached versions of an object.
+func cacheDir(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])
+	return hash[:2] + "/" + hash
+}
+
+// cacheKey returns the key of the specific object version. MetaInfo carries
+// no ETag, the version is only as precise as the bucket's modification time.
+func cacheKey(name string, info *bfs.MetaInfo) string {
+	version := strconv.FormatInt(info.ModTime.UnixNano(), 36) + "-" + strconv.FormatInt(info.Size, 36)
+	return path.Join(cacheDir(name), version)
+}
+
+// --------------------------------------------------------------------
+
+type writer struct {
+	bfs.Writer
+
+	bucket *bucket
+	ctx    context.Context
+	name   string
+	spool  *os.File
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	if w.spool != nil && n > 0 {
+		if _, err := w.spool.Write(p[:n]); err != nil {
+			w.closeSpool()
+		}
+	}
+	return n, err
+}
+
+func (w *writer) Discard() error {
+	w.closeSpool()
+	return w.Writer.Discard()
+}
+
+func (w *writer) Commit() error {
+	defer w.closeSpool()
+
+	if err := w.Writer.Commit(); err != nil {
+		return err
+	}
+	if err := w.bucket.invalidate(w.ctx, w.name); err != nil {
+		return err
+	}
+	if w.spool != nil {
+		// populating is best-effort, errors can be ignored
+		_ = w.populate()
+	}
+	return nil
+}
+
+func (w *writer) populate() error {
+	info, err := w.bucket.Bucket.Head(w.ctx, w.name)
+	if err != nil {
+		return err
+	}
+	if info.Size > w.bucket.config.MaxSize {
+		return nil
+	}
+
+	// skip if the object has been modified concurrently
+	if fi, err := w.spool.Stat(); err != nil {
+		return err
+	} else if fi.Size() != info.Size {
+		return nil
+	}
+
+	if _, err := w.spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return w.bucket.store(w.ctx, cacheKey(w.name, info), w.spool)
+}
+
+func (w *writer) closeSpool() {
+	if w.spool != nil {
+		_ = w.spool.Close()
+		_ = os.Remove(w.spool.Name())
+		w.spool = nil
+	}
+}
@@ -0,0 +1,255 @@
+package bfscache_test
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bsm/bfs"
+	"github.com/bsm/bfs/bfscache"
+	"github.com/bsm/bfs/bfstest"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("lint", func(t *testing.T) {
+		bucket, err := bfscache.New(bfs.NewInMem(), t.TempDir(), nil)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		defer bucket.Close()
+
+		bfstest.Common(t, bucket, bfstest.Supports{Metadata: true})
+	})
+
+	t.Run("caches", func(t *testing.T) {
+		ctx := t.Context()
+		base, opens := countOpens(bfs.NewInMem(), 0)
+		bucket, err := bfscache.New(base, t.TempDir(), nil)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		defer bucket.Close()
+
+		if err := bfs.WriteObject(ctx, base, "a.txt", []byte("v1"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		for i := 0; i < 3; i++ {
+			if exp, got := "v1", readString(t, bucket, "a.txt"); exp != got {
+				t.Errorf("Expected %v, got %v", exp, got)
+			}
+		}
+		if exp, got := int32(1), opens.Load(); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		// modify underlying object
+		if err := bfs.WriteObject(ctx, base, "a.txt", []byte("v2.0"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := "v2.0", readString(t, bucket, "a.txt"); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := int32(2), opens.Load(); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		// remove underlying object
+		if err := base.Remove(ctx, "a.txt"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if _, err := bucket.Open(ctx, "a.txt"); err != bfs.ErrNotFound {
+			t.Errorf("Expected %v, got %v", bfs.ErrNotFound, err)
+		}
+	})
+
+	t.Run("collapses concurrent opens", func(t *testing.T) {
+		ctx := t.Context()
+		base, opens := countOpens(bfs.NewInMem(), 20*time.Millisecond)
+		bucket, err := bfscache.New(base, t.TempDir(), nil)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		defer bucket.Close()
+
+		if err := bfs.WriteObject(ctx, base, "a.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if exp, got := "data", readString(t, bucket, "a.txt"); exp != got {
+					t.Errorf("Expected %v, got %v", exp, got)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if exp, got := int32(1), opens.Load(); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("survives cancelled downloads", func(t *testing.T) {
+		ctx := t.Context()
+		started := make(chan struct{})
+		var once sync.Once
+		base := bfs.Wrap(bfs.NewInMem(), bfs.Middleware{
+			Open: func(ctx context.Context, name string, next bfs.OpenFunc) (bfs.Reader, error) {
+				first := false
+				once.Do(func() { first = true })
+				if first {
+					close(started)
+					<-ctx.Done()
+					return nil, ctx.Err()
+				}
+				return next(ctx, name)
+			},
+		})
+		bucket, err := bfscache.New(base, t.TempDir(), nil)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		defer bucket.Close()
+
+		if err := bfs.WriteObject(ctx, base, "a.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		leaderCtx, cancel := context.WithCancel(ctx)
+		leaderErr := make(chan error, 1)
+		go func() {
+			_, err := bucket.Open(leaderCtx, "a.txt")
+			leaderErr <- err
+		}()
+		<-started
+
+		waiterErr := make(chan error, 1)
+		go func() {
+			r, err := bucket.Open(ctx, "a.txt")
+			if err == nil {
+				err = r.Close()
+			}
+			waiterErr <- err
+		}()
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+
+		if err := <-leaderErr; err != context.Canceled {
+			t.Errorf("Expected %v, got %v", context.Canceled, err)
+		}
+		if err := <-waiterErr; err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := "data", readString(t, bucket, "a.txt"); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("evicts", func(t *testing.T) {
+		ctx := t.Context()
+		base, opens := countOpens(bfs.NewInMem(), 0)
+		bucket, err := bfscache.New(base, t.TempDir(), &bfscache.Config{MaxSize: 10})
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		defer bucket.Close()
+
+		for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+			if err := bfs.WriteObject(ctx, base, name, []byte("data"), nil); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+		}
+
+		readString(t, bucket, "a.txt") // miss
+		readString(t, bucket, "b.txt") // miss
+		readString(t, bucket, "a.txt") // hit
+		readString(t, bucket, "c.txt") // miss, evicts b
+		readString(t, bucket, "a.txt") // hit
+		readString(t, bucket, "b.txt") // miss
+		if exp, got := int32(4), opens.Load(); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("invalidates and populates on write", func(t *testing.T) {
+		ctx := t.Context()
+		base, opens := countOpens(bfs.NewInMem(), 0)
+		bucket, err := bfscache.New(base, t.TempDir(), &bfscache.Config{Populate: true})
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		defer bucket.Close()
+
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("v1"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := "v1", readString(t, bucket, "a.txt"); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("v2.0"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := "v2.0", readString(t, bucket, "a.txt"); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := int32(0), opens.Load(); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("reuses existing entries", func(t *testing.T) {
+		ctx := t.Context()
+		dir := t.TempDir()
+		base, opens := countOpens(bfs.NewInMem(), 0)
+		if err := bfs.WriteObject(ctx, base, "a.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		for i := 0; i < 2; i++ {
+			bucket, err := bfscache.New(base, dir, nil)
+			if err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+			if exp, got := "data", readString(t, bucket, "a.txt"); exp != got {
+				t.Errorf("Expected %v, got %v", exp, got)
+			}
+		}
+		if exp, got := int32(1), opens.Load(); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+}
+
+// countOpens wraps a bucket, counts and optionally delays Open calls.
+func countOpens(bucket bfs.Bucket, delay time.Duration) (bfs.Bucket, *atomic.Int32) {
+	opens := new(atomic.Int32)
+	return bfs.Wrap(bucket, bfs.Middleware{
+		Open: func(ctx context.Context, name string, next bfs.OpenFunc) (bfs.Reader, error) {
+			opens.Add(1)
+			time.Sleep(delay)
+			return next(ctx, name)
+		},
+	}), opens
+}
+
+func readString(t *testing.T, bucket bfs.Bucket, name string) string {
+	t.Helper()
+
+	r, err := bucket.Open(t.Context(), name)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	return string(data)
+}
@@ -0,0 +1,302 @@
+// Package bfscompress transparently compresses objects on write and
+// decompresses them on read.
+//
+// The codec is chosen from the key suffix (".gz" for gzip, ".zst" for zstd)
+// or, for other keys, from the Content-Encoding metadata entry. Objects with
+// a codec suffix are stored with a matching ContentType (unless specified),
+// other objects are stored with a Content-Encoding metadata entry. Objects
+// without either are passed through as-is, unless a default codec is
+// configured.
+//
+// The logical (uncompressed) size is stored as Uncompressed-Size metadata
+// entry, Head reports it as MetaInfo.Size and adds the stored size as
+// Compressed-Size metadata entry. Please note that this requires metadata
+// support from the underlying bucket. Glob iterators are not wrapped, their
+// Size always reports the stored (compressed) size, which may therefore
+// differ from the size reported by Head for the same object.
+//
+//	import (
+//	  "github.com/bsm/bfs"
+//	  "github.com/bsm/bfs/bfscompress"
+//	)
+//
+//	func main() {
+//	  ctx := context.TODO()
+//	  b, _ := bfs.Connect(ctx, "s3://bucket/logs")
+//	  b, _ = bfscompress.New(b, nil)
+//	  w, _ := b.Create(ctx, "2024-01-01.log.zst", nil)
+//	  ...
+//	}
+package bfscompress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/bsm/bfs"
+)
+
+// Metadata keys.
+const (
+	MetaContentEncoding  = "Content-Encoding"
+	MetaUncompressedSize = "Uncompressed-Size"
+	MetaCompressedSize   = "Compressed-Size"
+)
+
+// Config is passed to New to configure compression.
+type Config struct {
+	// Codec is the default codec, used for objects without a codec suffix or
+	// Content-Encoding metadata entry. Supported: Gzip, Zstd. Default: none.
+	Codec string
+	// Level is the compression level. Default: codec-specific default.
+	Level int
+	// TempDir is used to spool compressed data. Default: os.TempDir().
+	TempDir string
+}
+
+func (c *Config) norm() error {
+	if c.Codec != "" && codecByName(c.Codec) == nil {
+		return fmt.Errorf("bfscompress: unsupported codec %q", c.Codec)
+	}
+	return nil
+}
+
+type bucket struct {
+	bfs.Bucket
+
+	config *Config
+	codec  *codec // default codec
+}
+
+// New wraps a bucket with transparent compression.
+func New(base bfs.Bucket, cfg *Config) (bfs.Bucket, error) {
+	config := new(Config)
+	if cfg != nil {
+		*config = *cfg
+	}
+	if err := config.norm(); err != nil {
+		return nil, err
+	}
+
+	return &bucket{
+		Bucket: base,
+		config: config,
+		codec:  codecByName(config.Codec),
+	}, nil
+}
+
+// Head implements bfs.Bucket. It reports the uncompressed size, unlike
+// Glob, which reports stored sizes.
+func (b *bucket) Head(ctx context.Context, name string) (*bfs.MetaInfo, error) {
+	info, err := b.Bucket.Head(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := strconv.ParseInt(info.Metadata.Get(MetaUncompressedSize), 10, 64)
+	if err != nil || b.detect(name, info.Metadata) == nil {
+		return info, nil
+	}
+
+	meta := make(bfs.Metadata, len(info.Metadata)+1)
+	for k, v := range info.Metadata {
+		meta[k] = v
+	}
+	meta.Set(MetaCompressedSize, strconv.FormatInt(info.Size, 10))
+
+	dup := *info
+	dup.Size = size
+	dup.Metadata = meta
+	return &dup, nil
+}
+
+// Open implements bfs.Bucket.
+func (b *bucket) Open(ctx context.Context, name string) (bfs.Reader, error) {
+	c := codecByExt(name)
+	if c == nil {
+		info, err := b.Bucket.Head(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		c = b.detect(name, info.Metadata)
+	}
+
+	r, err := b.Bucket.Open(ctx, name)
+	if err != nil || c == nil {
+		return r, err
+	}
+
+	dec, err := c.newReader(r)
+	if err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+	return &reader{ReadCloser: dec, raw: r}, nil
+}
+
+// Create implements bfs.Bucket.
+func (b *bucket) Create(ctx context.Context, name string, opts *bfs.WriteOptions) (bfs.Writer, error) {
+	// copy metadata, keys are added below and on commit
+	dstOpts := &bfs.WriteOptions{
+		ContentType: opts.GetContentType(),
+		Metadata:    maps.Clone(opts.GetMetadata()),
+		ModTime:     opts.GetModTime(),
+	}
+
+	c := codecByExt(name)
+	if c != nil {
+		if dstOpts.ContentType == "" {
+			dstOpts.ContentType = c.contentType
+		}
+	} else if s := dstOpts.Metadata.Get(MetaContentEncoding); s != "" {
+		if c = codecByName(s); c == nil {
+			return b.Bucket.Create(ctx, name, opts) // unknown encoding, pass through
+		}
+	} else if c = b.codec; c != nil {
+		if dstOpts.Metadata == nil {
+			dstOpts.Metadata = make(bfs.Metadata, 2)
+		}
+		dstOpts.Metadata.Set(MetaContentEncoding, c.name)
+	} else {
+		return b.Bucket.Create(ctx, name, opts)
+	}
+
+	f, err := os.CreateTemp(b.config.TempDir, "bfs-compress")
+	if err != nil {
+		return nil, err
+	}
+
+	enc, err := c.newWriter(f, b.config.Level)
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, err
+	}
+
+	return &writer{
+		enc:    enc,
+		spool:  f,
+		bucket: b,
+		ctx:    ctx,
+		name:   name,
+		opts:   dstOpts,
+	}, nil
+}
+
+// Copy supports the optional Copy extension. Objects are copied natively
+// only if source and destination use the same codec suffix.
+func (b *bucket) Copy(ctx context.Context, src, dst string) error {
+	copier, ok := b.Bucket.(interface {
+		Copy(context.Context, string, string) error
+	})
+	if !ok || codecByExt(src) != codecByExt(dst) {
+		return errors.ErrUnsupported
+	}
+	return copier.Copy(ctx, src, dst)
+}
+
+// detect returns the codec of an existing object.
+func (b *bucket) detect(name string, meta bfs.Metadata) *codec {
+	if c := codecByExt(name); c != nil {
+		return c
+	}
+	return codecByName(meta.Get(MetaContentEncoding))
+}
+
+// --------------------------------------------------------------------
+
+type reader struct {
+	io.ReadCloser
+
+	raw bfs.Reader
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 && err == io.EOF {
+		err = nil // report EOF on next read
+	}
+	return n, err
+}
+
+func (r *reader) Close() error {
+	err := r.ReadCloser.Close()
+	if err2 := r.raw.Close(); err2 != nil && err == nil {
+		err = err2
+	}
+	return err
+}
+
+type writer struct {
+	enc   io.WriteCloser
+	spool *os.File
+	size  int64
+
+	bucket *bucket
+	ctx    context.Context
+	name   string
+	opts   *bfs.WriteOptions
+
+	closeOnce sync.Once
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	n, err := w.enc.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *writer) Discard() error {
+	err := os.ErrClosed
+	w.closeOnce.Do(func() {
+		defer os.Remove(w.spool.Name())
+
+		_ = w.enc.Close()
+		err = w.spool.Close()
+	})
+	return err
+}
+
+func (w *writer) Commit() error {
+	err := os.ErrClosed
+	w.closeOnce.Do(func() {
+		defer os.Remove(w.spool.Name())
+		defer w.spool.Close()
+
+		if err = w.enc.Close(); err != nil {
+			return
+		} else if err = w.ctx.Err(); err != nil {
+			return
+		}
+		err = w.upload()
+	})
+	return err
+}
+
+func (w *writer) upload() error {
+	if _, err := w.spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if w.opts.Metadata == nil {
+		w.opts.Metadata = make(bfs.Metadata, 1)
+	}
+	w.opts.Metadata.Set(MetaUncompressedSize, strconv.FormatInt(w.size, 10))
+
+	dst, err := w.bucket.Bucket.Create(w.ctx, w.name, w.opts)
+	if err != nil {
+		return err
+	}
+	defer dst.Discard()
+
+	if _, err := io.Copy(dst, w.spool); err != nil {
+		return err
+	}
+	return dst.Commit()
+}
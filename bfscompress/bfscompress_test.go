@@ -0,0 +1,166 @@
+package bfscompress_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bsm/bfs"
+	"github.com/bsm/bfs/bfscompress"
+	"github.com/bsm/bfs/bfstest"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("lint", func(t *testing.T) {
+		bucket, err := bfscompress.New(bfs.NewInMem(), nil)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		bfstest.Common(t, bucket, bfstest.Supports{Metadata: true})
+	})
+
+	t.Run("lint with default codec", func(t *testing.T) {
+		bucket, err := bfscompress.New(bfs.NewInMem(), &bfscompress.Config{Codec: bfscompress.Zstd})
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		bfstest.Common(t, bucket, bfstest.Supports{})
+	})
+
+	t.Run("validates", func(t *testing.T) {
+		if _, err := bfscompress.New(bfs.NewInMem(), &bfscompress.Config{Codec: "lzma"}); err == nil {
+			t.Errorf("Expected error")
+		}
+	})
+
+	t.Run("does not modify options", func(t *testing.T) {
+		ctx := t.Context()
+		bucket, err := bfscompress.New(bfs.NewInMem(), &bfscompress.Config{Codec: bfscompress.Gzip})
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		opts := &bfs.WriteOptions{Metadata: bfs.Metadata{"X-Owner": "tests"}}
+		for _, name := range []string{"a.txt", "b.log.zst"} {
+			if err := bfs.WriteObject(ctx, bucket, name, []byte("data"), opts); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+		}
+		if exp, got := (bfs.Metadata{"X-Owner": "tests"}), opts.Metadata; !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("compresses by suffix", func(t *testing.T) {
+		ctx := t.Context()
+		base := bfs.NewInMem()
+		bucket, err := bfscompress.New(base, nil)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		data := strings.Repeat("log line\n", 1000)
+		for _, name := range []string{"a.log.gz", "a.log.zst"} {
+			if err := bfs.WriteObject(ctx, bucket, name, []byte(data), nil); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+
+			raw, err := base.Head(ctx, name)
+			if err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+			if raw.Size >= int64(len(data)) {
+				t.Errorf("Expected %s to be compressed, got %d bytes", name, raw.Size)
+			}
+
+			info, err := bucket.Head(ctx, name)
+			if err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+			if exp, got := int64(len(data)), info.Size; exp != got {
+				t.Errorf("Expected %v, got %v", exp, got)
+			}
+			if exp, got := (bfs.Metadata{
+				"Uncompressed-Size": "9000",
+				"Compressed-Size":   strconv.FormatInt(raw.Size, 10),
+			}), info.Metadata; !reflect.DeepEqual(exp, got) {
+				t.Errorf("Expected %v, got %v", exp, got)
+			}
+
+			if exp, got := data, readString(t, bucket, name); exp != got {
+				t.Errorf("Expected %d bytes, got %d", len(exp), len(got))
+			}
+		}
+
+		// stored objects are valid gzip
+		r, err := base.Open(ctx, "a.log.gz")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		defer r.Close()
+
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		plain, err := io.ReadAll(gz)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := data, string(plain); exp != got {
+			t.Errorf("Expected %d bytes, got %d", len(exp), len(got))
+		}
+	})
+
+	t.Run("compresses by content encoding", func(t *testing.T) {
+		ctx := t.Context()
+		base := bfs.NewInMem()
+		bucket, err := bfscompress.New(base, nil)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		data := bytes.Repeat([]byte("x"), 1000)
+		opts := &bfs.WriteOptions{Metadata: bfs.Metadata{"Content-Encoding": "gzip"}}
+		if err := bfs.WriteObject(ctx, bucket, "a.bin", data, opts); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bfs.WriteObject(ctx, bucket, "b.bin", data, nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		sizes := base.ObjectSizes()
+		if sizes["a.bin"] >= 1000 {
+			t.Errorf("Expected a.bin to be compressed, got %d bytes", sizes["a.bin"])
+		}
+		if exp, got := int64(1000), sizes["b.bin"]; exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := string(data), readString(t, bucket, "a.bin"); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := string(data), readString(t, bucket, "b.bin"); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+}
+
+func readString(t *testing.T, bucket bfs.Bucket, name string) string {
+	t.Helper()
+
+	r, err := bucket.Open(t.Context(), name)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	return string(data)
+}
@@ -0,0 +1,79 @@
+package bfscompress
+
+import (
+	"compress/gzip"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Supported codecs.
+const (
+	Gzip = "gzip"
+	Zstd = "zstd"
+)
+
+type codec struct {
+	name        string
+	ext         string
+	contentType string
+	newWriter   func(io.Writer, int) (io.WriteCloser, error)
+	newReader   func(io.Reader) (io.ReadCloser, error)
+}
+
+var codecs = []*codec{
+	{
+		name:        Gzip,
+		ext:         ".gz",
+		contentType: "application/gzip",
+		newWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+			if level == 0 {
+				level = gzip.DefaultCompression
+			}
+			return gzip.NewWriterLevel(w, level)
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+	{
+		name:        Zstd,
+		ext:         ".zst",
+		contentType: "application/zstd",
+		newWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+			lvl := zstd.SpeedDefault
+			if level != 0 {
+				lvl = zstd.EncoderLevelFromZstd(level)
+			}
+			return zstd.NewWriter(w, zstd.WithEncoderLevel(lvl), zstd.WithEncoderConcurrency(1))
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			dec, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				return nil, err
+			}
+			return dec.IOReadCloser(), nil
+		},
+	},
+}
+
+// codecByName returns a codec by name (case-insensitive).
+func codecByName(name string) *codec {
+	for _, c := range codecs {
+		if strings.EqualFold(c.name, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// codecByExt returns a codec matching the key suffix.
+func codecByExt(key string) *codec {
+	for _, c := range codecs {
+		if strings.HasSuffix(key, c.ext) {
+			return c
+		}
+	}
+	return nil
+}
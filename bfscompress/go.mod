@@ -0,0 +1,10 @@
+module github.com/bsm/bfs/bfscompress
+
+go 1.25
+
+require (
+	github.com/bsm/bfs v0.13.0
+	github.com/klauspost/compress v1.20.1
+)
+
+require github.com/bmatcuk/doublestar/v4 v4.9.1 // indirect
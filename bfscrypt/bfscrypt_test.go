@@ -0,0 +1,214 @@
+package bfscrypt_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/bsm/bfs"
+	"github.com/bsm/bfs/bfscrypt"
+	"github.com/bsm/bfs/bfsfs"
+	"github.com/bsm/bfs/bfstest"
+)
+
+var masterKey = bytes.Repeat([]byte{0x42}, 32)
+
+func TestNew(t *testing.T) {
+	keys, err := bfscrypt.NewStaticKeyProvider("k1", masterKey, nil)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+
+	t.Run("lint metadata", func(t *testing.T) {
+		bucket, err := bfscrypt.New(bfs.NewInMem(), &bfscrypt.Config{KeyProvider: keys, ChunkSize: 5})
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		bfstest.Common(t, bucket, bfstest.Supports{Metadata: true})
+	})
+
+	t.Run("lint header", func(t *testing.T) {
+		base, err := bfsfs.New(t.TempDir(), "")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		bucket, err := bfscrypt.New(base, &bfscrypt.Config{KeyProvider: keys, Header: true, ChunkSize: 5})
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		bfstest.Common(t, bucket, bfstest.Supports{})
+	})
+
+	t.Run("validates", func(t *testing.T) {
+		if _, err := bfscrypt.New(bfs.NewInMem(), nil); err == nil {
+			t.Errorf("Expected error")
+		}
+		if _, err := bfscrypt.NewStaticKeyProvider("k1", []byte("short"), nil); err == nil {
+			t.Errorf("Expected error")
+		}
+	})
+
+	t.Run("does not modify options", func(t *testing.T) {
+		ctx := t.Context()
+		bucket, err := bfscrypt.New(bfs.NewInMem(), &bfscrypt.Config{KeyProvider: keys})
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		opts := &bfs.WriteOptions{Metadata: bfs.Metadata{"X-Owner": "tests"}}
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), opts); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := (bfs.Metadata{"X-Owner": "tests"}), opts.Metadata; !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	for _, header := range []bool{false, true} {
+		cfg := &bfscrypt.Config{KeyProvider: keys, Header: header, ChunkSize: 16}
+		name := "metadata"
+		if header {
+			name = "header"
+		}
+
+		t.Run(name+" round-trips", func(t *testing.T) {
+			ctx := t.Context()
+			base := bfs.NewInMem()
+			bucket, err := bfscrypt.New(base, cfg)
+			if err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+
+			for _, size := range []int{0, 1, 15, 16, 17, 48, 1000} {
+				data := bytes.Repeat([]byte("s3cr3t!"), size/7+1)[:size]
+				if err := bfs.WriteObject(ctx, bucket, "obj", data, nil); err != nil {
+					t.Fatal("Unexpected error", err)
+				}
+
+				info, err := bucket.Head(ctx, "obj")
+				if err != nil {
+					t.Fatal("Unexpected error", err)
+				}
+				if exp, got := int64(size), info.Size; exp != got {
+					t.Errorf("Expected %v, got %v", exp, got)
+				}
+				if exp, got := data, readAll(t, bucket, "obj"); !bytes.Equal(exp, got) {
+					t.Errorf("Expected %q, got %q", exp, got)
+				}
+
+				if raw := readAll(t, base, "obj"); size > 6 && bytes.Contains(raw, data[:7]) {
+					t.Errorf("Expected %q to be encrypted", raw)
+				}
+			}
+		})
+
+		t.Run(name+" detects tampering", func(t *testing.T) {
+			ctx := t.Context()
+			base := bfs.NewInMem()
+			bucket, err := bfscrypt.New(base, cfg)
+			if err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+			if err := bfs.WriteObject(ctx, bucket, "obj", bytes.Repeat([]byte("x"), 40), nil); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+			info, err := base.Head(ctx, "obj")
+			if err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+			raw := readAll(t, base, "obj")
+
+			// truncate after the first two chunks
+			truncated := raw[:len(raw)-(40-32+16)]
+			if err := bfs.WriteObject(ctx, base, "obj", truncated, &bfs.WriteOptions{Metadata: info.Metadata}); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+			if _, err := readAllErr(ctx, bucket, "obj"); err == nil {
+				t.Errorf("Expected error")
+			}
+
+			// flip a bit
+			raw[len(raw)-1] ^= 1
+			if err := bfs.WriteObject(ctx, base, "obj", raw, &bfs.WriteOptions{Metadata: info.Metadata}); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+			if _, err := readAllErr(ctx, bucket, "obj"); err == nil {
+				t.Errorf("Expected error")
+			}
+		})
+	}
+
+	t.Run("rotates keys", func(t *testing.T) {
+		ctx := t.Context()
+		base := bfs.NewInMem()
+		bucket, err := bfscrypt.New(base, &bfscrypt.Config{KeyProvider: keys})
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bfs.WriteObject(ctx, bucket, "obj", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		newKey := bytes.Repeat([]byte{0x43}, 32)
+		rotated, err := bfscrypt.NewStaticKeyProvider("k2", newKey, map[string][]byte{"k1": masterKey})
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		bucket, err = bfscrypt.New(base, &bfscrypt.Config{KeyProvider: rotated})
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := "data", string(readAll(t, bucket, "obj")); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		other, err := bfscrypt.NewStaticKeyProvider("k2", newKey, nil)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		bucket, err = bfscrypt.New(base, &bfscrypt.Config{KeyProvider: other})
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if _, err := readAllErr(ctx, bucket, "obj"); err == nil {
+			t.Errorf("Expected error")
+		}
+	})
+
+	t.Run("rejects unencrypted", func(t *testing.T) {
+		ctx := t.Context()
+		base := bfs.NewInMem()
+		bucket, err := bfscrypt.New(base, &bfscrypt.Config{KeyProvider: keys})
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bfs.WriteObject(ctx, base, "plain", []byte("plaintext"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if _, err := bucket.Open(ctx, "plain"); err != bfscrypt.ErrNotEncrypted {
+			t.Errorf("Expected %v, got %v", bfscrypt.ErrNotEncrypted, err)
+		}
+	})
+}
+
+func readAll(t *testing.T, bucket bfs.Bucket, name string) []byte {
+	t.Helper()
+
+	data, err := readAllErr(t.Context(), bucket, name)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	return data
+}
+
+func readAllErr(ctx context.Context, bucket bfs.Bucket, name string) ([]byte, error) {
+	r, err := bucket.Open(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
@@ -0,0 +1,87 @@
+package bfscrypt
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// KeyProvider wraps and unwraps per-object data keys, e.g. using a key
+// management service.
+type KeyProvider interface {
+	// WrapKey encrypts a data key. It returns the wrapped key together with
+	// the ID of the key encryption key.
+	WrapKey(ctx context.Context, key []byte) (wrapped []byte, keyID string, err error)
+	// UnwrapKey decrypts a wrapped data key.
+	UnwrapKey(ctx context.Context, wrapped []byte, keyID string) ([]byte, error)
+}
+
+// NewStaticKeyProvider returns a key provider which wraps data keys with
+// a static, 32 byte long key encryption key using AES-256-GCM. Additional
+// keys can be passed to decrypt objects encrypted with older (rotated) keys.
+func NewStaticKeyProvider(keyID string, key []byte, previous map[string][]byte) (KeyProvider, error) {
+	p := &staticKeyProvider{
+		keyID: keyID,
+		keys:  make(map[string]cipher.AEAD, len(previous)+1),
+	}
+	for id, k := range previous {
+		if err := p.add(id, k); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.add(keyID, key); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+type staticKeyProvider struct {
+	keyID string
+	keys  map[string]cipher.AEAD
+}
+
+func (p *staticKeyProvider) add(keyID string, key []byte) error {
+	if len(key) != 32 {
+		return fmt.Errorf("bfscrypt: invalid length of key %q, must be 32 bytes", keyID)
+	}
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	p.keys[keyID] = aead
+	return nil
+}
+
+// WrapKey implements KeyProvider.
+func (p *staticKeyProvider) WrapKey(_ context.Context, key []byte) ([]byte, string, error) {
+	aead := p.keys[p.keyID]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(key)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, "", err
+	}
+	return aead.Seal(nonce, nonce, key, []byte(p.keyID)), p.keyID, nil
+}
+
+// UnwrapKey implements KeyProvider.
+func (p *staticKeyProvider) UnwrapKey(_ context.Context, wrapped []byte, keyID string) ([]byte, error) {
+	aead, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("bfscrypt: unknown key %q", keyID)
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errors.New("bfscrypt: invalid wrapped key")
+	}
+	return aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], []byte(keyID))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,189 @@
+package bfscrypt
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/bsm/bfs"
+)
+
+// Data is encrypted in chunks, each chunk is sealed individually with a
+// nonce derived from the chunk position and a flag which marks the final
+// chunk. This prevents reordering and truncation of chunks.
+
+var errTruncated = errors.New("bfscrypt: encrypted data is truncated")
+
+func chunkNonce(aead cipher.AEAD, nonce []byte, counter uint32, last bool) []byte {
+	nonce = nonce[:aead.NonceSize()]
+	clear(nonce)
+	binary.BigEndian.PutUint32(nonce[len(nonce)-5:], counter)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// plainSize calculates the plaintext size from the ciphertext size.
+func plainSize(size int64, chunkSize, overhead int) int64 {
+	if size <= 0 {
+		return 0
+	}
+
+	sealed := int64(chunkSize + overhead)
+	chunks := (size + sealed - 1) / sealed
+	return max(size-chunks*int64(overhead), 0)
+}
+
+// --------------------------------------------------------------------
+
+type writer struct {
+	raw     bfs.Writer
+	aead    cipher.AEAD
+	buf     []byte
+	out     []byte
+	nonce   []byte
+	counter uint32
+}
+
+func newWriter(raw bfs.Writer, aead cipher.AEAD, chunkSize int) *writer {
+	return &writer{
+		raw:   raw,
+		aead:  aead,
+		buf:   make([]byte, 0, chunkSize),
+		out:   make([]byte, 0, chunkSize+aead.Overhead()),
+		nonce: make([]byte, aead.NonceSize()),
+	}
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	var written int
+	for len(p) != 0 {
+		// only seal full chunks once more data arrives,
+		// the final chunk is sealed on Commit
+		if len(w.buf) == cap(w.buf) {
+			if err := w.seal(false); err != nil {
+				return written, err
+			}
+		}
+
+		n := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (w *writer) Discard() error {
+	return w.raw.Discard()
+}
+
+func (w *writer) Commit() error {
+	if err := w.seal(true); err != nil {
+		return err
+	}
+	return w.raw.Commit()
+}
+
+func (w *writer) seal(last bool) error {
+	nonce := chunkNonce(w.aead, w.nonce, w.counter, last)
+	w.out = w.aead.Seal(w.out[:0], nonce, w.buf, nil)
+	w.buf = w.buf[:0]
+	w.counter++
+
+	_, err := w.raw.Write(w.out)
+	return err
+}
+
+// --------------------------------------------------------------------
+
+type reader struct {
+	raw     bfs.Reader
+	br      *bufio.Reader
+	aead    cipher.AEAD
+	buf     []byte // decrypted
+	pos     int
+	cbuf    []byte
+	nonce   []byte
+	counter uint32
+	done    bool
+	err     error
+}
+
+func newReader(raw bfs.Reader, br *bufio.Reader, aead cipher.AEAD, chunkSize int) *reader {
+	sealed := chunkSize + aead.Overhead()
+	if br == nil {
+		br = bufio.NewReaderSize(raw, sealed+1)
+	}
+	return &reader{
+		raw:   raw,
+		br:    br,
+		aead:  aead,
+		cbuf:  make([]byte, sealed),
+		nonce: make([]byte, aead.NonceSize()),
+	}
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	var n int
+	for n < len(p) {
+		if r.pos == len(r.buf) {
+			if r.err != nil {
+				break
+			} else if r.done {
+				r.err = io.EOF
+				break
+			} else if r.err = r.open(); r.err != nil {
+				break
+			}
+		}
+
+		m := copy(p[n:], r.buf[r.pos:])
+		r.pos += m
+		n += m
+	}
+
+	if n > 0 {
+		return n, nil
+	}
+	return 0, r.err
+}
+
+func (r *reader) Close() error {
+	return r.raw.Close()
+}
+
+// open reads and decrypts the next chunk.
+func (r *reader) open() error {
+	n, err := io.ReadFull(r.br, r.cbuf)
+	last := false
+	switch err {
+	case nil:
+		_, perr := r.br.Peek(1)
+		if perr == io.EOF {
+			last = true
+		} else if perr != nil {
+			return perr
+		}
+	case io.ErrUnexpectedEOF:
+		last = true
+	case io.EOF:
+		return errTruncated
+	default:
+		return err
+	}
+
+	nonce := chunkNonce(r.aead, r.nonce, r.counter, last)
+	plain, err := r.aead.Open(r.buf[:0], nonce, r.cbuf[:n], nil)
+	if err != nil {
+		return err
+	}
+
+	r.buf, r.pos = plain, 0
+	r.counter++
+	r.done = last
+	return nil
+}
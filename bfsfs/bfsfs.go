@@ -22,10 +22,14 @@ package bfsfs
 
 import (
 	"context"
+	"errors"
+	"io"
+	"io/fs"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"syscall"
 
 	"github.com/bsm/bfs"
 )
@@ -59,6 +63,8 @@ type atomicFile struct {
 	ctx  context.Context
 	root *os.Root
 	name string
+
+	exclusive bool
 }
 
 // openAtomicFile opens atomic file for writing.
@@ -102,7 +108,51 @@ func (f *atomicFile) Commit() error {
 		return err
 	}
 
-	return os.Rename(f.Name(), filepath.Join(f.root.Name(), path.Clean("/"+f.name)))
+	target := filepath.Join(f.root.Name(), path.Clean("/"+f.name))
+	if f.exclusive {
+		return f.link(target)
+	}
+	return os.Rename(f.Name(), target)
+}
+
+// link exclusively links the file to the target. If the temporary file is
+// located on a different device, it is first copied into the target
+// directory and linked from there, so the target never appears partially
+// written.
+func (f *atomicFile) link(target string) error {
+	err := os.Link(f.Name(), target)
+	if errors.Is(err, fs.ErrExist) {
+		return bfs.ErrExists
+	} else if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	src, err := os.Open(f.Name())
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".github_com__bsm__bfs__bfsfs")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, src); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Link(tmp.Name(), target); errors.Is(err, fs.ErrExist) {
+		return bfs.ErrExists
+	} else if err != nil {
+		return err
+	}
+	return nil
 }
 
 // cleanup removes temporary file.
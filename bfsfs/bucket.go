@@ -108,6 +108,17 @@ func (b *bucket) Create(ctx context.Context, name string, _ *bfs.WriteOptions) (
 	return f, nil
 }
 
+// CreateNew implements bfs.Bucket extension. The returned writer
+// fails to commit with bfs.ErrExists if the object already exists.
+func (b *bucket) CreateNew(ctx context.Context, name string, opts *bfs.WriteOptions) (bfs.Writer, error) {
+	f, err := openAtomicFile(ctx, b.root, filepath.FromSlash(name), b.tmpDir)
+	if err != nil {
+		return nil, normError(err)
+	}
+	f.exclusive = true
+	return f, nil
+}
+
 // Remove implements bfs.Bucket
 func (b *bucket) Remove(ctx context.Context, name string) error {
 	err := b.root.Remove(filepath.FromSlash(name))
@@ -1,11 +1,16 @@
 package bfsfs_test
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
+	"time"
 
+	"github.com/bsm/bfs"
 	"github.com/bsm/bfs/bfsfs"
-	"github.com/bsm/bfs/testdata/lint"
+	"github.com/bsm/bfs/bfstest"
 )
 
 func Test(t *testing.T) {
@@ -20,7 +25,116 @@ func Test(t *testing.T) {
 		t.Fatal("Unexpected error", err)
 	}
 
-	support := lint.Supports{}
-	lint.Common(t, bucket, support)
-	lint.Slow(t, bucket, support)
+	support := bfstest.Supports{UnicodeKeys: true, ConcurrentWrites: true}
+	bfstest.Common(t, bucket, support)
+	bfstest.Slow(t, bucket, support)
+}
+
+func TestWriteOnce(t *testing.T) {
+	ctx := t.Context()
+	for _, tmpDir := range []string{"", "same"} {
+		dir := t.TempDir()
+		if tmpDir != "" {
+			tmpDir = dir
+		}
+
+		base, err := bfsfs.New(dir, tmpDir)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		bucket := bfs.WriteOnce(base)
+
+		if err := bfs.WriteObject(ctx, bucket, "a/b.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bfs.WriteObject(ctx, bucket, "a/b.txt", []byte("data"), nil); !errors.Is(err, bfs.ErrExists) {
+			t.Errorf("Expected %v, got %v", bfs.ErrExists, err)
+		}
+	}
+}
+
+func TestWriteOnce_crossDevice(t *testing.T) {
+	tmpDir := "/dev/shm"
+	if _, err := os.Stat(tmpDir); err != nil {
+		t.Skip("no separate temporary file system available")
+	}
+
+	ctx := t.Context()
+	dir := t.TempDir()
+	base, err := bfsfs.New(dir, tmpDir)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	bucket := bfs.WriteOnce(base)
+
+	if err := bfs.WriteObject(ctx, bucket, "a/b.txt", []byte("data"), nil); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if err := bfs.WriteObject(ctx, bucket, "a/b.txt", []byte("other"), nil); !errors.Is(err, bfs.ErrExists) {
+		t.Errorf("Expected %v, got %v", bfs.ErrExists, err)
+	}
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(filepath.Join(dir, "a"))
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if exp, got := 1, len(entries); exp != got {
+		t.Errorf("Expected %v, got %v", exp, got)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "a", "b.txt")); err != nil {
+		t.Fatal("Unexpected error", err)
+	} else if exp, got := "data", string(data); exp != got {
+		t.Errorf("Expected %v, got %v", exp, got)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("native notifications are only supported on Linux")
+	}
+
+	ctx := t.Context()
+	bucket, err := bfsfs.New(t.TempDir(), "")
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if err := bfs.WriteObject(ctx, bucket, "a/b.txt", []byte("data"), nil); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+
+	// rely on native notifications only
+	events, err := bfs.Watch(ctx, bucket, "**/*.txt", time.Hour)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+
+	receive := func(exp bfs.EventType, name string) {
+		t.Helper()
+
+		select {
+		case e := <-events:
+			if e.Type != exp || e.Name != name {
+				t.Errorf("Expected %v %v, got %v %v", exp, name, e.Type, e.Name)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timeout waiting for %v %v", exp, name)
+		}
+	}
+
+	if err := bfs.WriteObject(ctx, bucket, "a/c/d.txt", []byte("data"), nil); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	receive(bfs.Created, "a/c/d.txt")
+
+	// ensure new sub-directories are watched
+	if err := bfs.WriteObject(ctx, bucket, "a/c/e.txt", []byte("data"), nil); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	receive(bfs.Created, "a/c/e.txt")
+
+	if err := bucket.Remove(ctx, "a/b.txt"); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	receive(bfs.Removed, "a/b.txt")
 }
@@ -0,0 +1,142 @@
+package bfsfs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+const notifyMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_ATTRIB |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE
+
+// Notify implements bfs.Bucket extension for native change notifications
+// using inotify. All directories below the root are watched recursively.
+func (b *bucket) Notify(ctx context.Context, pattern string) (<-chan struct{}, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	// a non-blocking fd is integrated with the runtime poller,
+	// hence Close unblocks pending reads
+	n := &notifier{
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		fd:      fd,
+		root:    b.root.Name(),
+		pattern: pattern,
+		dirs:    make(map[int32]string),
+		ch:      make(chan struct{}, 1),
+	}
+	if err := n.addTree("."); err != nil {
+		_ = n.file.Close()
+		return nil, err
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = n.file.Close()
+	}()
+	go n.loop()
+	return n.ch, nil
+}
+
+type notifier struct {
+	file    *os.File
+	fd      int
+	root    string
+	pattern string
+	dirs    map[int32]string // watch descriptors to relative dirs
+	ch      chan struct{}
+}
+
+// addTree watches dir and all its sub-directories.
+func (n *notifier) addTree(dir string) error {
+	return filepath.WalkDir(filepath.Join(n.root, dir), func(name string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		} else if !d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(n.root, name)
+		if err != nil {
+			return err
+		}
+
+		wd, err := syscall.InotifyAddWatch(n.fd, name, notifyMask)
+		if errors.Is(err, syscall.ENOENT) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		n.dirs[int32(wd)] = rel
+		return nil
+	})
+}
+
+func (n *notifier) loop() {
+	defer close(n.ch)
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		sz, err := n.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		changed := false
+		for pos := 0; pos+syscall.SizeofInotifyEvent <= sz; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[pos]))
+			raw := buf[pos+syscall.SizeofInotifyEvent : pos+syscall.SizeofInotifyEvent+int(ev.Len)]
+			pos += syscall.SizeofInotifyEvent + int(ev.Len)
+
+			if ev.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				changed = true
+				continue
+			}
+
+			dir, ok := n.dirs[ev.Wd]
+			if !ok {
+				continue
+			}
+			if ev.Mask&syscall.IN_IGNORED != 0 {
+				delete(n.dirs, ev.Wd)
+				continue
+			}
+
+			name := filepath.Join(dir, cstring(raw))
+			if ev.Mask&syscall.IN_ISDIR != 0 {
+				if ev.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+					_ = n.addTree(name)
+				}
+				changed = true
+			} else if ok, _ := doublestar.Match(n.pattern, filepath.ToSlash(name)); ok {
+				changed = true
+			}
+		}
+
+		if changed {
+			select {
+			case n.ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func cstring(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package bfsfs
+
+import (
+	"context"
+	"errors"
+)
+
+// Notify implements bfs.Bucket extension. Native change notifications are
+// only supported on Linux.
+func (*bucket) Notify(context.Context, string) (<-chan struct{}, error) {
+	return nil, errors.ErrUnsupported
+}
@@ -8,45 +8,31 @@ import (
 
 	"github.com/bsm/bfs"
 	"github.com/bsm/bfs/bfsftp"
-	"github.com/bsm/bfs/testdata/lint"
-	. "github.com/bsm/ginkgo/v2"
-	. "github.com/bsm/gomega"
+	"github.com/bsm/bfs/bfstest"
 )
 
 const (
 	serverAddr = "127.0.0.1:7021"
 )
 
-var _ = Describe("Bucket", func() {
-	var opts lint.Options
-
-	BeforeEach(func() {
-		prefix := "x/" + strconv.FormatInt(time.Now().UnixNano(), 10)
-		subject, err := bfsftp.New(serverAddr, &bfsftp.Config{Prefix: prefix, Username: "ftpuser", Password: "ftppass"})
-		Expect(err).NotTo(HaveOccurred())
-
-		opts = lint.Options{
-			Subject: subject,
-		}
-	})
-
-	Context("defaults", lint.Lint(&opts))
-})
-
-// ------------------------------------------------------------------------
-
-func TestSuite(t *testing.T) {
-	if err := sandboxCheck(); err != nil {
+func Test(t *testing.T) {
+	if err := sandboxCheck(t.Context()); err != nil {
 		t.Skipf("skipping test, no sandbox access: %v", err)
-		return
 	}
 
-	RegisterFailHandler(Fail)
-	RunSpecs(t, "bfs/bfsftp")
+	prefix := "x/" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	bucket, err := bfsftp.New(serverAddr, &bfsftp.Config{Prefix: prefix, Username: "ftpuser", Password: "ftppass"})
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	defer bucket.Close()
+	defer bfs.RemoveAll(context.Background(), bucket, "**")
+
+	bfstest.Common(t, bucket, bfstest.Supports{})
+	bfstest.Slow(t, bucket, bfstest.Supports{})
 }
 
-func sandboxCheck() error {
-	ctx := context.Background()
+func sandboxCheck(ctx context.Context) error {
 	b, err := bfsftp.New(serverAddr, &bfsftp.Config{Username: "ftpuser", Password: "ftppass"})
 	if err != nil {
 		return err
@@ -58,19 +44,3 @@ func sandboxCheck() error {
 	}
 	return nil
 }
-
-var _ = AfterSuite(func() {
-	ctx := context.Background()
-	b, err := bfsftp.New(serverAddr, &bfsftp.Config{Prefix: "x/", Username: "ftpuser", Password: "ftppass"})
-	Expect(err).NotTo(HaveOccurred())
-	defer b.Close()
-
-	it, err := b.Glob(ctx, "**")
-	Expect(err).NotTo(HaveOccurred())
-	defer it.Close()
-
-	for it.Next() {
-		Expect(b.Remove(ctx, it.Name())).To(Succeed())
-	}
-	Expect(it.Error()).NotTo(HaveOccurred())
-})
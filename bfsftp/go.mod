@@ -1,16 +1,15 @@
 module github.com/bsm/bfs/bfsftp
 
-go 1.21
+go 1.25
 
 require (
 	github.com/bmatcuk/doublestar/v3 v3.0.0
-	github.com/bsm/bfs v0.11.9
-	github.com/bsm/ginkgo/v2 v2.1.3
-	github.com/bsm/gomega v1.16.0
+	github.com/bsm/bfs v0.13.0
 	github.com/jlaffaye/ftp v0.2.0
 )
 
 require (
+	github.com/bmatcuk/doublestar/v4 v4.9.1 // indirect
 	github.com/hashicorp/errwrap v1.1.0 // indirect
 	github.com/hashicorp/go-multierror v1.1.1 // indirect
 )
@@ -22,9 +22,14 @@
 package bfsgs
 
 import (
+	"cmp"
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
 	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -32,6 +37,7 @@ import (
 	doublestar "github.com/bmatcuk/doublestar/v3"
 	"github.com/bsm/bfs"
 	"github.com/bsm/bfs/internal"
+	"google.golang.org/api/googleapi"
 	giterator "google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
@@ -135,7 +141,19 @@ func (b *bucket) Glob(ctx context.Context, pattern string) (bfs.Iterator, error)
 
 // Head implements bfs.Bucket.
 func (b *bucket) Head(ctx context.Context, name string) (*bfs.MetaInfo, error) {
-	obj := b.bucket.Object(b.withPrefix(name))
+	return b.head(ctx, name, b.bucket.Object(b.withPrefix(name)))
+}
+
+// HeadVersion implements bfs.Versioned, version IDs are object generations.
+func (b *bucket) HeadVersion(ctx context.Context, name, versionID string) (*bfs.MetaInfo, error) {
+	obj, err := b.objectVersion(name, versionID)
+	if err != nil {
+		return nil, err
+	}
+	return b.head(ctx, name, obj)
+}
+
+func (*bucket) head(ctx context.Context, name string, obj *storage.ObjectHandle) (*bfs.MetaInfo, error) {
 	attrs, err := obj.Attrs(ctx)
 	if err != nil {
 		return nil, normError(err)
@@ -157,11 +175,35 @@ func (b *bucket) Open(ctx context.Context, name string) (bfs.Reader, error) {
 	return ord, normError(err)
 }
 
+// OpenVersion implements bfs.Versioned, version IDs are object generations.
+func (b *bucket) OpenVersion(ctx context.Context, name, versionID string) (bfs.Reader, error) {
+	obj, err := b.objectVersion(name, versionID)
+	if err != nil {
+		return nil, err
+	}
+	ord, err := obj.NewReader(ctx)
+	return ord, normError(err)
+}
+
 // Create implements bfs.Bucket.
 func (b *bucket) Create(ctx context.Context, name string, opts *bfs.WriteOptions) (bfs.Writer, error) {
+	return b.create(ctx, name, opts, nil)
+}
+
+// CreateNew supports exclusive creation of objects using preconditions.
+// Commits fail with bfs.ErrExists if the object already exists.
+func (b *bucket) CreateNew(ctx context.Context, name string, opts *bfs.WriteOptions) (bfs.Writer, error) {
+	return b.create(ctx, name, opts, &storage.Conditions{DoesNotExist: true})
+}
+
+// create opens a writer, applying optional preconditions.
+func (b *bucket) create(ctx context.Context, name string, opts *bfs.WriteOptions, conds *storage.Conditions) (bfs.Writer, error) {
 	ctx, cancel := context.WithCancel(ctx)
 
 	obj := b.bucket.Object(b.withPrefix(name))
+	if conds != nil {
+		obj = obj.If(*conds)
+	}
 	wrt := obj.NewWriter(ctx)
 	wrt.PredefinedACL = b.config.PredefinedACL
 	wrt.ContentType = opts.GetContentType()
@@ -179,6 +221,81 @@ func (b *bucket) Remove(ctx context.Context, name string) error {
 	return err
 }
 
+// RemoveVersion implements bfs.Versioned, version IDs are object generations.
+func (b *bucket) RemoveVersion(ctx context.Context, name, versionID string) error {
+	obj, err := b.objectVersion(name, versionID)
+	if err != nil {
+		return err
+	}
+	return normError(obj.Delete(ctx))
+}
+
+// ListVersions implements bfs.Versioned. Object versioning must be enabled
+// on the bucket to retain noncurrent generations. Google Cloud Storage does
+// not create delete markers.
+func (b *bucket) ListVersions(ctx context.Context, name string) ([]*bfs.VersionInfo, error) {
+	key := b.withPrefix(name)
+	iter := b.bucket.Objects(ctx, &storage.Query{
+		Prefix:   key,
+		Versions: true,
+	})
+
+	var versions []*bfs.VersionInfo
+	for {
+		attrs, err := iter.Next()
+		if err == giterator.Done {
+			break
+		} else if err != nil {
+			return nil, normError(err)
+		}
+		if attrs.Name != key {
+			continue
+		}
+
+		versions = append(versions, &bfs.VersionInfo{
+			MetaInfo: bfs.MetaInfo{
+				Name:        name,
+				Size:        attrs.Size,
+				ModTime:     attrs.Updated,
+				ContentType: attrs.ContentType,
+				Metadata:    bfs.NormMetadata(attrs.Metadata),
+			},
+			VersionID: strconv.FormatInt(attrs.Generation, 10),
+			IsLatest:  attrs.Deleted.IsZero(),
+		})
+	}
+	if len(versions) == 0 {
+		return nil, bfs.ErrNotFound
+	}
+
+	// newest generations first
+	slices.SortFunc(versions, func(a, b *bfs.VersionInfo) int {
+		x, _ := strconv.ParseInt(a.VersionID, 10, 64)
+		y, _ := strconv.ParseInt(b.VersionID, 10, 64)
+		return cmp.Compare(y, x)
+	})
+	return versions, nil
+}
+
+// RestoreVersion restores a specific generation as the current version of
+// an object by copying it server-side.
+func (b *bucket) RestoreVersion(ctx context.Context, name, versionID string) error {
+	src, err := b.objectVersion(name, versionID)
+	if err != nil {
+		return err
+	}
+	_, err = b.bucket.Object(b.withPrefix(name)).CopierFrom(src).Run(ctx)
+	return normError(err)
+}
+
+func (b *bucket) objectVersion(name, versionID string) (*storage.ObjectHandle, error) {
+	gen, err := strconv.ParseInt(versionID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("bfsgs: invalid generation %q", versionID)
+	}
+	return b.bucket.Object(b.withPrefix(name)).Generation(gen), nil
+}
+
 // Copy supports copying of objects within the bucket.
 func (b *bucket) Copy(ctx context.Context, src, dst string) error {
 	_, err := b.bucket.Object(b.withPrefix(dst)).CopierFrom(
@@ -196,6 +313,11 @@ func normError(err error) error {
 	if err == storage.ErrObjectNotExist {
 		return bfs.ErrNotFound
 	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed {
+		return bfs.ErrExists
+	}
 	return err
 }
 
@@ -222,7 +344,7 @@ func (w *writer) Commit() error {
 	err := w.ctx.Err()
 
 	if ezz := w.Close(); ezz != nil {
-		err = ezz
+		err = normError(ezz)
 	}
 	w.cancel() // cancel AFTER close
 
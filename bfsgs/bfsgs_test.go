@@ -2,59 +2,122 @@ package bfsgs_test
 
 import (
 	"context"
+	"errors"
 	"strconv"
 	"testing"
 	"time"
 
 	"github.com/bsm/bfs"
 	"github.com/bsm/bfs/bfsgs"
-	"github.com/bsm/bfs/testdata/lint"
-
-	. "github.com/bsm/ginkgo/v2"
-	. "github.com/bsm/gomega"
+	"github.com/bsm/bfs/bfstest"
 )
 
-const bucketName = "bsm-bfs-unittest"
+const (
+	bucketName         = "bsm-bfs-unittest"
+	numReadonlySamples = 2121
+)
 
-var _ = Describe("Bucket", func() {
-	var opts lint.Options
+func Test(t *testing.T) {
+	if err := sandboxCheck(t.Context()); err != nil {
+		t.Skipf("skipping test, no sandbox access: %v", err)
+	}
 
-	BeforeEach(func() {
-		ctx := context.Background()
+	prefix := "x/" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	bucket, err := bfsgs.New(t.Context(), bucketName, &bfsgs.Config{Prefix: prefix})
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	defer bucket.Close()
+	defer bfs.RemoveAll(context.Background(), bucket, "**")
 
-		prefix := "x/" + strconv.FormatInt(time.Now().UnixNano(), 10)
-		subject, err := bfsgs.New(ctx, bucketName, &bfsgs.Config{Prefix: prefix})
-		Expect(err).NotTo(HaveOccurred())
+	t.Run("common", func(t *testing.T) {
+		bfstest.Common(t, bucket, bfstest.Supports{ContentType: true, Metadata: true})
+	})
 
-		readonly, err := bfsgs.New(ctx, bucketName, &bfsgs.Config{Prefix: "m/"})
-		Expect(err).NotTo(HaveOccurred())
+	t.Run("reads fixtures", func(t *testing.T) {
+		readonly, err := bfsgs.New(t.Context(), bucketName, &bfsgs.Config{Prefix: "m/"})
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		defer readonly.Close()
 
-		opts = lint.Options{
-			Subject:  subject,
-			Readonly: readonly,
+		for _, pattern := range []string{"*/*", "**"} {
+			if exp, got := numReadonlySamples, countEntries(t, readonly, pattern); exp != got {
+				t.Errorf("Expected %v, got %v", exp, got)
+			}
+		}
+	})
 
-			Metadata:    true,
-			ContentType: true,
+	t.Run("write once", func(t *testing.T) {
+		ctx := t.Context()
+		once := bfs.WriteOnce(bucket)
+		if err := bfs.WriteObject(ctx, once, "once.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bfs.WriteObject(ctx, once, "once.txt", []byte("data"), nil); !errors.Is(err, bfs.ErrExists) {
+			t.Errorf("Expected %v, got %v", bfs.ErrExists, err)
 		}
 	})
 
-	Context("defaults", lint.Lint(&opts))
-})
+	t.Run("versioning", func(t *testing.T) {
+		ctx := t.Context()
+		versioned := bucket.(bfs.Versioned)
 
-// ------------------------------------------------------------------------
+		for _, data := range []string{"v1", "v2"} {
+			if err := bfs.WriteObject(ctx, bucket, "versioned.txt", []byte(data), &bfs.WriteOptions{ContentType: "text/plain"}); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+		}
 
-func TestSuite(t *testing.T) {
-	if err := sandboxCheck(); err != nil {
-		t.Skipf("skipping test, no sandbox access: %v", err)
-		return
+		versions, err := versioned.ListVersions(ctx, "versioned.txt")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if len(versions) == 0 || !versions[0].IsLatest {
+			t.Fatalf("Expected latest version first, got %+v", versions)
+		}
+
+		latest := versions[0].VersionID
+		info, err := versioned.HeadVersion(ctx, "versioned.txt", latest)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := int64(2), info.Size; exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		if err := bfs.RestoreVersion(ctx, versioned, "versioned.txt", latest); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := versioned.RemoveVersion(ctx, "versioned.txt", latest); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if _, err := versioned.HeadVersion(ctx, "versioned.txt", latest); err != bfs.ErrNotFound {
+			t.Errorf("Expected %v, got %v", bfs.ErrNotFound, err)
+		}
+	})
+}
+
+func countEntries(t *testing.T, bucket bfs.Bucket, pattern string) int {
+	t.Helper()
+
+	iter, err := bucket.Glob(t.Context(), pattern)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
 	}
+	defer iter.Close()
 
-	RegisterFailHandler(Fail)
-	RunSpecs(t, "bfs/bfsgs")
+	n := 0
+	for iter.Next() {
+		n++
+	}
+	if err := iter.Error(); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	return n
 }
 
-func sandboxCheck() error {
-	ctx := context.Background()
+func sandboxCheck(ctx context.Context) error {
 	b, err := bfsgs.New(ctx, bucketName, nil)
 	if err != nil {
 		return err
@@ -66,19 +129,3 @@ func sandboxCheck() error {
 	}
 	return nil
 }
-
-var _ = AfterSuite(func() {
-	ctx := context.Background()
-	b, err := bfsgs.New(ctx, bucketName, &bfsgs.Config{Prefix: "x/"})
-	Expect(err).NotTo(HaveOccurred())
-	defer b.Close()
-
-	it, err := b.Glob(ctx, "**")
-	Expect(err).NotTo(HaveOccurred())
-	defer it.Close()
-
-	for it.Next() {
-		Expect(b.Remove(ctx, it.Name())).To(Succeed())
-	}
-	Expect(it.Error()).NotTo(HaveOccurred())
-})
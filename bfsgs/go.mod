@@ -1,13 +1,11 @@
 module github.com/bsm/bfs/bfsgs
 
-go 1.23.0
+go 1.25
 
 require (
 	cloud.google.com/go/storage v1.40.0
 	github.com/bmatcuk/doublestar/v3 v3.0.0
-	github.com/bsm/bfs v0.11.9
-	github.com/bsm/ginkgo/v2 v2.1.3
-	github.com/bsm/gomega v1.16.0
+	github.com/bsm/bfs v0.13.0
 	google.golang.org/api v0.177.0
 )
 
@@ -17,6 +15,7 @@ require (
 	cloud.google.com/go/auth/oauth2adapt v0.2.2 // indirect
 	cloud.google.com/go/compute/metadata v0.3.0 // indirect
 	cloud.google.com/go/iam v1.1.8 // indirect
+	github.com/bmatcuk/doublestar/v4 v4.9.1 // indirect
 	github.com/felixge/httpsnoop v1.0.4 // indirect
 	github.com/go-logr/logr v1.4.1 // indirect
 	github.com/go-logr/stdr v1.2.2 // indirect
@@ -0,0 +1,566 @@
+// Package bfshttp abstracts a (read-only) HTTP(S) file server.
+//
+// When imported, it registers global `http://` and `https://` scheme resolvers and can be used like:
+//
+//	import (
+//	  "github.com/bsm/bfs"
+//
+//	  _ "github.com/bsm/bfs/bfshttp"
+//	)
+//
+//	func main() {
+//	  ctx := context.TODO()
+//	  b, _ := bfs.Connect(ctx, "https://example.com/path/to/root?index=MANIFEST")
+//	  f, _ := b.Open(ctx, "file/within/root.txt") // GET https://example.com/path/to/root/file/within/root.txt
+//	  ...
+//	}
+//
+// Glob is supported either through an index file, which lists one object
+// name per line (optionally followed by tab-separated size and RFC 3339
+// modification time), or by crawling auto-generated HTML directory listings.
+//
+// bfs.Connect supports the following query parameters:
+//
+//	index    - name of an index file, relative to the root
+//	writable - enables writes via PUT and removals via DELETE
+//	tmpdir   - custom temp dir
+package bfshttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/bsm/bfs"
+	"github.com/bsm/bfs/internal"
+)
+
+// MetaHeaderPrefix is the prefix of HTTP headers which carry object metadata.
+const MetaHeaderPrefix = "X-Object-Meta-"
+
+var errReadOnly = errors.New("bfshttp: bucket is read-only")
+
+func init() {
+	resolver := func(_ context.Context, u *url.URL) (bfs.Bucket, error) {
+		query := u.Query()
+
+		writable := false
+		if s := query.Get("writable"); s != "" {
+			v, err := strconv.ParseBool(s)
+			if err != nil {
+				return nil, err
+			}
+			writable = v
+		}
+
+		base := *u
+		base.RawQuery = ""
+		base.Fragment = ""
+
+		return New(base.String(), &Config{
+			Index:    query.Get("index"),
+			Writable: writable,
+			TempDir:  query.Get("tmpdir"),
+		})
+	}
+
+	bfs.Register("http", resolver)
+	bfs.Register("https", resolver)
+}
+
+// Config is passed to New to configure the HTTP client.
+type Config struct {
+	// A custom HTTP client, defaults to http.DefaultClient.
+	Client *http.Client
+	// Custom headers to send with every request.
+	Header http.Header
+	// An optional index file name, relative to the root. When set, Glob reads
+	// object names from the index instead of crawling HTML directory listings.
+	Index string
+	// Enables Create and Remove, mapped to PUT and DELETE requests.
+	Writable bool
+	// A custom temp dir.
+	TempDir string
+}
+
+func (c *Config) norm() error {
+	if c.Client == nil {
+		c.Client = http.DefaultClient
+	}
+	c.Index = strings.TrimLeft(c.Index, "/")
+	return nil
+}
+
+type bucket struct {
+	base   *url.URL
+	config *Config
+}
+
+// New initiates an bfs.Bucket backed by an HTTP(S) server.
+// All object names are resolved relative to rootURL.
+func New(rootURL string, cfg *Config) (bfs.Bucket, error) {
+	config := new(Config)
+	if cfg != nil {
+		*config = *cfg
+	}
+	if err := config.norm(); err != nil {
+		return nil, err
+	}
+
+	base, err := url.Parse(rootURL)
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("bfshttp: invalid URL scheme %q", base.Scheme)
+	}
+	base.Path = strings.TrimRight(base.Path, "/")
+	base.RawPath = ""
+	base.RawQuery = ""
+	base.Fragment = ""
+
+	return &bucket{
+		base:   base,
+		config: config,
+	}, nil
+}
+
+// objectURL returns the full URL of an object. Directory URLs
+// must be requested with a trailing slash.
+func (b *bucket) objectURL(name string, dir bool) string {
+	u := *b.base
+	u.Path = internal.WithinNamespace("/"+b.base.Path, name)
+	if dir && !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return u.String()
+}
+
+func (b *bucket) newRequest(ctx context.Context, method, rawURL string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, vv := range b.config.Header {
+		req.Header[k] = append(req.Header[k], vv...)
+	}
+	return req, nil
+}
+
+// do performs a request, returning an error for unsuccessful responses.
+func (b *bucket) do(req *http.Request) (*http.Response, error) {
+	resp, err := b.config.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkResponse(req, resp); err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Glob implements bfs.Bucket.
+func (b *bucket) Glob(ctx context.Context, pattern string) (bfs.Iterator, error) {
+	// quick sanity check
+	if _, err := doublestar.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	iter := &iterator{
+		bucket:  b,
+		pattern: pattern,
+		ctx:     ctx,
+		pos:     -1,
+	}
+	if pattern == "" {
+		return iter, nil
+	}
+
+	if b.config.Index != "" {
+		files, err := b.readIndex(ctx, pattern)
+		if err != nil {
+			return nil, err
+		}
+		iter.files = files
+		return iter, nil
+	}
+
+	dir, _ := doublestar.SplitPattern(pattern)
+	if dir == "." {
+		dir = ""
+	}
+	iter.subdirs = []string{dir}
+	return iter, nil
+}
+
+// Head implements bfs.Bucket.
+func (b *bucket) Head(ctx context.Context, name string) (*bfs.MetaInfo, error) {
+	req, err := b.newRequest(ctx, http.MethodHead, b.objectURL(name, false), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.do(req)
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+
+	info := &bfs.MetaInfo{
+		Name:        name,
+		Size:        resp.ContentLength,
+		ContentType: resp.Header.Get("Content-Type"),
+		Metadata:    bfs.Metadata{},
+	}
+	if info.Size < 0 {
+		info.Size = 0
+	}
+	if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = t
+	}
+	for k := range resp.Header {
+		if key, ok := strings.CutPrefix(k, MetaHeaderPrefix); ok && key != "" {
+			info.Metadata.Set(key, resp.Header.Get(k))
+		}
+	}
+	return info, nil
+}
+
+// Open implements bfs.Bucket.
+func (b *bucket) Open(ctx context.Context, name string) (bfs.Reader, error) {
+	rd := &reader{
+		ctx:    ctx,
+		bucket: b,
+		url:    b.objectURL(name, false),
+		size:   -1,
+	}
+	if err := rd.open(); err != nil {
+		return nil, err
+	}
+	return rd, nil
+}
+
+// Create implements bfs.Bucket.
+func (b *bucket) Create(ctx context.Context, name string, opts *bfs.WriteOptions) (bfs.Writer, error) {
+	if !b.config.Writable {
+		return nil, errReadOnly
+	}
+
+	f, err := os.CreateTemp(b.config.TempDir, "bfs-http")
+	if err != nil {
+		return nil, err
+	}
+
+	return &writer{
+		File:   f,
+		ctx:    ctx,
+		bucket: b,
+		name:   name,
+		opts:   opts,
+	}, nil
+}
+
+// Remove implements bfs.Bucket.
+func (b *bucket) Remove(ctx context.Context, name string) error {
+	if !b.config.Writable {
+		return errReadOnly
+	}
+
+	req, err := b.newRequest(ctx, http.MethodDelete, b.objectURL(name, false), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := b.do(req)
+	if errors.Is(err, bfs.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Close implements bfs.Bucket.
+func (*bucket) Close() error { return nil }
+
+// --------------------------------------------------------------------
+
+// StatusError is returned when the server responds with an unexpected
+// status code.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+}
+
+// Error implements error interface.
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("bfshttp: %s %s: unexpected status %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+func checkResponse(req *http.Request, resp *http.Response) error {
+	switch {
+	case resp.StatusCode == http.StatusNotFound, resp.StatusCode == http.StatusGone:
+		return bfs.ErrNotFound
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return &StatusError{Method: req.Method, URL: req.URL.String(), StatusCode: resp.StatusCode}
+	}
+
+	// servers commonly redirect "path/to" to the directory listing at
+	// "path/to/", these are not objects
+	if resp.Request != nil && req.Method != http.MethodPut &&
+		!strings.HasSuffix(req.URL.Path, "/") && strings.HasSuffix(resp.Request.URL.Path, "/") {
+		return bfs.ErrNotFound
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------
+
+type writer struct {
+	*os.File
+
+	ctx    context.Context
+	bucket *bucket
+	name   string
+	opts   *bfs.WriteOptions
+
+	closeOnce sync.Once
+}
+
+func (w *writer) Discard() error {
+	err := os.ErrClosed
+	w.closeOnce.Do(func() {
+		// delete tempfile in the end
+		fname := w.Name()
+		defer os.Remove(fname)
+
+		// close tempfile
+		err = w.Close()
+	})
+	return err
+}
+
+func (w *writer) Commit() error {
+	err := os.ErrClosed
+	w.closeOnce.Do(func() {
+		// delete tempfile in the end
+		fname := w.Name()
+		defer os.Remove(fname)
+
+		// close tempfile, check context
+		if err = w.Close(); err != nil {
+			return
+		} else if err = w.ctx.Err(); err != nil {
+			return
+		}
+
+		// reopen for reading
+		var file *os.File
+		if file, err = os.Open(fname); err != nil {
+			return
+		}
+		defer file.Close()
+
+		var fi os.FileInfo
+		if fi, err = file.Stat(); err != nil {
+			return
+		}
+
+		var req *http.Request
+		if req, err = w.bucket.newRequest(w.ctx, http.MethodPut, w.bucket.objectURL(w.name, false), file); err != nil {
+			return
+		}
+		req.ContentLength = fi.Size()
+		if ct := w.opts.GetContentType(); ct != "" {
+			req.Header.Set("Content-Type", ct)
+		}
+		for k, v := range w.opts.GetMetadata() {
+			req.Header.Set(MetaHeaderPrefix+k, v)
+		}
+
+		var resp *http.Response
+		if resp, err = w.bucket.do(req); err != nil {
+			return
+		}
+		err = resp.Body.Close()
+	})
+	return err
+}
+
+// --------------------------------------------------------------------
+
+// reader reads an object, it issues Range requests to support seeking
+// and partial reads.
+type reader struct {
+	ctx    context.Context
+	bucket *bucket
+	url    string
+
+	body io.ReadCloser
+	pos  int64
+	size int64 // -1 if unknown
+}
+
+// open (re-)opens the response body at the current position.
+func (r *reader) open() error {
+	req, err := r.bucket.newRequest(r.ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return err
+	}
+	if r.pos > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(r.pos, 10)+"-")
+	}
+
+	resp, err := r.bucket.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		_ = resp.Body.Close()
+		r.body = http.NoBody
+		return nil
+	}
+	if err := checkResponse(req, resp); err != nil {
+		_ = resp.Body.Close()
+		return err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		if size := parseContentRangeSize(resp.Header.Get("Content-Range")); size > -1 {
+			r.size = size
+		}
+	case r.pos > 0: // server ignored the range, skip ahead
+		r.size = resp.ContentLength
+		if _, err := io.CopyN(io.Discard, resp.Body, r.pos); err != nil && err != io.EOF {
+			_ = resp.Body.Close()
+			return err
+		}
+	default:
+		r.size = resp.ContentLength
+	}
+
+	r.body = resp.Body
+	return nil
+}
+
+// Read implements io.Reader.
+func (r *reader) Read(p []byte) (int, error) {
+	if r.body == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.body.Read(p)
+	r.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// Seek implements io.Seeker.
+func (r *reader) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = r.pos + offset
+	case io.SeekEnd:
+		if r.size < 0 {
+			return 0, errors.New("bfshttp: seek relative to unknown size")
+		}
+		pos = r.size + offset
+	default:
+		return 0, errors.New("bfshttp: invalid whence")
+	}
+	if pos < 0 {
+		return 0, errors.New("bfshttp: negative position")
+	}
+
+	if pos != r.pos {
+		if r.body != nil {
+			_ = r.body.Close()
+			r.body = nil
+		}
+		r.pos = pos
+	}
+	return pos, nil
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *reader) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	req, err := r.bucket.newRequest(r.ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", "bytes="+strconv.FormatInt(off, 10)+"-"+strconv.FormatInt(off+int64(len(p))-1, 10))
+
+	resp, err := r.bucket.config.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		return 0, io.EOF
+	}
+	if err := checkResponse(req, resp); err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusPartialContent && off > 0 {
+		if _, err := io.CopyN(io.Discard, resp.Body, off); err == io.EOF {
+			return 0, io.EOF
+		} else if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := io.ReadFull(resp.Body, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Close implements io.Closer.
+func (r *reader) Close() error {
+	if r.body != nil {
+		err := r.body.Close()
+		r.body = nil
+		return err
+	}
+	return nil
+}
+
+// parseContentRangeSize extracts the complete length from a
+// "bytes 0-99/1234" header value, returns -1 if unknown.
+func parseContentRangeSize(s string) int64 {
+	_, total, ok := strings.Cut(s, "/")
+	if !ok {
+		return -1
+	}
+	n, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
@@ -0,0 +1,177 @@
+package bfshttp_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bsm/bfs"
+	"github.com/bsm/bfs/bfshttp"
+	"github.com/bsm/bfs/bfstest"
+)
+
+func Test(t *testing.T) {
+	server := httptest.NewServer(newFileServer(t.TempDir()))
+	defer server.Close()
+
+	bucket, err := bfshttp.New(server.URL+"/root", &bfshttp.Config{Writable: true})
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	defer bucket.Close()
+
+	support := bfstest.Supports{UnicodeKeys: true, ConcurrentWrites: true, ReadCancellation: true}
+	bfstest.Common(t, bucket, support)
+	bfstest.Slow(t, bucket, support)
+}
+
+func TestIndex(t *testing.T) {
+	ctx := t.Context()
+	dir := t.TempDir()
+	writeFile(t, dir, "root/MANIFEST", "# generated\na/b.txt\t4\t2024-01-02T03:04:05Z\n./c.txt\n\nd/e.json\t2\n")
+	writeFile(t, dir, "root/a/b.txt", "DATA")
+	writeFile(t, dir, "root/c.txt", "MORE")
+
+	server := httptest.NewServer(newFileServer(dir))
+	defer server.Close()
+
+	bucket, err := bfs.Connect(ctx, server.URL+"/root?index=MANIFEST")
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	defer bucket.Close()
+
+	iter, err := bucket.Glob(ctx, "**/*.txt")
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	defer iter.Close()
+
+	var names []string
+	var sizes []int64
+	for iter.Next() {
+		names = append(names, iter.Name())
+		sizes = append(sizes, iter.Size())
+	}
+	if err := iter.Error(); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+
+	if exp := []string{"a/b.txt", "c.txt"}; !reflect.DeepEqual(exp, names) {
+		t.Errorf("Expected %v, got %v", exp, names)
+	}
+	if exp := []int64{4, 4}; !reflect.DeepEqual(exp, sizes) {
+		t.Errorf("Expected %v, got %v", exp, sizes)
+	}
+
+	if _, err := bucket.Create(ctx, "x.txt", nil); err == nil {
+		t.Error("Expected error, but got none")
+	}
+	if err := bucket.Remove(ctx, "c.txt"); err == nil {
+		t.Error("Expected error, but got none")
+	}
+}
+
+func TestReader(t *testing.T) {
+	ctx := t.Context()
+	dir := t.TempDir()
+	writeFile(t, dir, "file.txt", "0123456789")
+
+	server := httptest.NewServer(newFileServer(dir))
+	defer server.Close()
+
+	bucket, err := bfshttp.New(server.URL, nil)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	defer bucket.Close()
+
+	r, err := bucket.Open(ctx, "file.txt")
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	defer r.Close()
+
+	t.Run("read at", func(t *testing.T) {
+		p := make([]byte, 4)
+		if n, err := r.(io.ReaderAt).ReadAt(p, 3); err != nil {
+			t.Fatal("Unexpected error", err)
+		} else if exp, got := "3456", string(p[:n]); exp != got {
+			t.Errorf("Expected %q, got %q", exp, got)
+		}
+
+		if n, err := r.(io.ReaderAt).ReadAt(p, 8); !errors.Is(err, io.EOF) {
+			t.Errorf("Expected %v, got %v", io.EOF, err)
+		} else if exp, got := "89", string(p[:n]); exp != got {
+			t.Errorf("Expected %q, got %q", exp, got)
+		}
+	})
+
+	t.Run("seek", func(t *testing.T) {
+		if _, err := r.(io.Seeker).Seek(-4, io.SeekEnd); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if data, err := io.ReadAll(r); err != nil {
+			t.Fatal("Unexpected error", err)
+		} else if exp, got := "6789", string(data); exp != got {
+			t.Errorf("Expected %q, got %q", exp, got)
+		}
+	})
+}
+
+// --------------------------------------------------------------------
+
+// newFileServer returns a http.FileServer with support for PUT and DELETE.
+func newFileServer(dir string) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := filepath.Join(dir, filepath.FromSlash(r.URL.Path))
+
+		switch r.Method {
+		case http.MethodPut:
+			if err := os.MkdirAll(filepath.Dir(name), 0o777); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			data, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := os.WriteFile(name, data, 0o666); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodDelete:
+			if fi, err := os.Stat(name); err != nil || !fi.Mode().IsRegular() {
+				http.NotFound(w, r)
+				return
+			}
+			if err := os.Remove(name); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			files.ServeHTTP(w, r)
+		}
+	})
+}
+
+func writeFile(t *testing.T, dir, name, data string) {
+	t.Helper()
+
+	name = filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(name), 0o777); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if err := os.WriteFile(name, []byte(data), 0o666); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+}
@@ -0,0 +1,270 @@
+package bfshttp
+
+import (
+	"bufio"
+	"context"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/bsm/bfs"
+)
+
+var hrefPattern = regexp.MustCompile(`(?i)<a\s[^>]*?href\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+// file is an entry of an index or a directory listing.
+type file struct {
+	name    string
+	size    int64
+	modTime time.Time
+	stat    bool // set when size and modTime are known
+}
+
+// readIndex reads the configured index file and returns entries
+// matching the pattern.
+func (b *bucket) readIndex(ctx context.Context, pattern string) ([]*file, error) {
+	req, err := b.newRequest(ctx, http.MethodGet, b.objectURL(b.config.Index, false), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return parseIndex(resp.Body, pattern)
+}
+
+// parseIndex parses an index, one entry per line:
+//
+//	name[<TAB>size[<TAB>modtime]]
+//
+// Blank lines and lines starting with '#' are ignored.
+func parseIndex(r io.Reader, pattern string) ([]*file, error) {
+	var files []*file
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		name := strings.TrimLeft(strings.TrimPrefix(fields[0], "./"), "/")
+		if ok, err := doublestar.Match(pattern, name); err != nil {
+			return nil, err
+		} else if !ok {
+			continue
+		}
+
+		f := &file{name: name}
+		if len(fields) > 1 {
+			size, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			f.size = size
+			f.stat = true
+		}
+		if len(fields) > 2 {
+			t, err := time.Parse(time.RFC3339, fields[2])
+			if err != nil {
+				return nil, err
+			}
+			f.modTime = t
+		}
+		files = append(files, f)
+	}
+	return files, scanner.Err()
+}
+
+// readDir fetches a HTML directory listing and returns files
+// matching the pattern as well as sub-directories.
+func (b *bucket) readDir(ctx context.Context, pattern, dir string, subdirs []string) ([]*file, []string, error) {
+	dirURL := b.objectURL(dir, true)
+	req, err := b.newRequest(ctx, http.MethodGet, dirURL, nil)
+	if err != nil {
+		return nil, subdirs, err
+	}
+
+	resp, err := b.config.Client.Do(req)
+	if err != nil {
+		return nil, subdirs, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(req, resp); err == bfs.ErrNotFound {
+		return nil, subdirs, nil
+	} else if err != nil {
+		return nil, subdirs, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, subdirs, err
+	}
+
+	var files []*file
+	for _, name := range parseListing(resp.Request.URL, body) {
+		if base, ok := strings.CutSuffix(name, "/"); ok {
+			subdirs = append(subdirs, path.Join(dir, base))
+			continue
+		}
+
+		name = path.Join(dir, name)
+		if ok, err := doublestar.Match(pattern, name); err != nil {
+			return nil, subdirs, err
+		} else if ok {
+			files = append(files, &file{name: name})
+		}
+	}
+	return files, subdirs, nil
+}
+
+// parseListing extracts the names of direct children from an auto-generated
+// HTML directory listing. Directory names are returned with a trailing slash.
+func parseListing(dirURL *url.URL, body []byte) []string {
+	var names []string
+
+	seen := make(map[string]struct{})
+	for _, m := range hrefPattern.FindAllSubmatch(body, -1) {
+		href := string(m[1])
+		if href == "" {
+			href = string(m[2])
+		}
+
+		ref, err := url.Parse(html.UnescapeString(href))
+		if err != nil || ref.RawQuery != "" || ref.Fragment != "" {
+			continue
+		}
+
+		abs := dirURL.ResolveReference(ref)
+		if abs.Scheme != dirURL.Scheme || abs.Host != dirURL.Host {
+			continue
+		}
+
+		name, ok := strings.CutPrefix(abs.Path, dirURL.Path)
+		if !ok || name == "" || name == "/" || name[0] == '/' {
+			continue
+		}
+		if i := strings.IndexByte(name, '/'); i > -1 && i != len(name)-1 {
+			continue
+		}
+
+		if _, ok := seen[name]; !ok {
+			seen[name] = struct{}{}
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// --------------------------------------------------------------------
+
+type iterator struct {
+	bucket  *bucket
+	pattern string
+	files   []*file
+	subdirs []string
+
+	ctx context.Context
+	err error
+	pos int
+}
+
+func (i *iterator) Close() error {
+	i.files = i.files[:0]
+	i.subdirs = i.subdirs[:0]
+	return nil
+}
+
+func (i *iterator) Name() string {
+	if f := i.current(); f != nil {
+		return f.name
+	}
+	return ""
+}
+
+func (i *iterator) Size() int64 {
+	if f := i.stat(); f != nil {
+		return f.size
+	}
+	return 0
+}
+
+func (i *iterator) ModTime() time.Time {
+	if f := i.stat(); f != nil {
+		return f.modTime
+	}
+	return time.Time{}
+}
+
+func (i *iterator) Next() bool {
+	if i.err != nil {
+		return false
+	}
+
+	if err := i.ctx.Err(); err != nil {
+		i.err = err
+		return false
+	}
+
+	if i.pos++; i.pos < len(i.files) {
+		return true
+	}
+
+	if len(i.subdirs) == 0 {
+		return false
+	}
+
+	// pop last dir
+	tail := i.subdirs[len(i.subdirs)-1]
+	i.subdirs = i.subdirs[:len(i.subdirs)-1]
+
+	// read dir
+	files, subdirs, err := i.bucket.readDir(i.ctx, i.pattern, tail, i.subdirs)
+	if err != nil {
+		i.err = err
+		return false
+	}
+
+	i.pos = -1
+	i.files = files
+	i.subdirs = subdirs
+	return i.Next()
+}
+
+func (i *iterator) Error() error { return i.err }
+
+func (i *iterator) current() *file {
+	if i.pos > -1 && i.pos < len(i.files) {
+		return i.files[i.pos]
+	}
+	return nil
+}
+
+// stat returns the current file, lazily fetching
+// size and modification time if unknown.
+func (i *iterator) stat() *file {
+	f := i.current()
+	if f == nil || f.stat {
+		return f
+	}
+
+	f.stat = true
+	if info, err := i.bucket.Head(i.ctx, f.name); err == nil {
+		f.size = info.Size
+		f.modTime = info.ModTime
+	}
+	return f
+}
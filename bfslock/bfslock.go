@@ -0,0 +1,325 @@
+// Package bfslock implements distributed leases on top of buckets, e.g. to
+// ensure that scheduled jobs run on a single host only.
+//
+// Each lease is stored as a lock object below the lease name, holding the
+// owner, an expiry time and a fencing token. Leases are acquired by atomically
+// creating the lock object for the next token, hence buckets must support
+// exclusive creation natively, e.g. InMem, bfsfs, bfss3 and bfsgs, also when
+// wrapped by middlewares. Otherwise, New or Acquire fail with
+// bfs.ErrNoExclusiveCreate.
+// Expired leases are taken over by creating the lock object for the next
+// token, the previous holder's lock object is never overwritten.
+//
+// Expiry is evaluated using the local clock, leases must therefore be renewed
+// well ahead of their expiry to tolerate clock skew between hosts.
+//
+//	import (
+//	  "github.com/bsm/bfs"
+//	  "github.com/bsm/bfs/bfslock"
+//	)
+//
+//	func main() {
+//	  ctx := context.TODO()
+//	  b, _ := bfs.Connect(ctx, "s3://bucket/locks")
+//	  locker, _ := bfslock.New(b, &bfslock.Config{TTL: time.Minute})
+//
+//	  lease, err := locker.Acquire(ctx, "cron/daily-report")
+//	  if errors.Is(err, bfslock.ErrLocked) {
+//	    return // running elsewhere
+//	  }
+//	  defer lease.Release(ctx)
+//	  ...
+//	}
+package bfslock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bsm/bfs"
+)
+
+var (
+	// ErrLocked is returned when a lease is held by another owner.
+	ErrLocked = errors.New("bfslock: lease is held by another owner")
+	// ErrLost is returned when a lease has expired or has been taken over.
+	ErrLost = errors.New("bfslock: lease lost")
+)
+
+// Config is passed to New to configure leases.
+type Config struct {
+	// Owner identifies the lease holder.
+	// Default: hostname:pid
+	Owner string
+	// TTL is the duration of a lease.
+	// Default: 30s
+	TTL time.Duration
+	// Clock returns the current time.
+	// Default: time.Now
+	Clock func() time.Time
+}
+
+func (c *Config) norm() {
+	if c.Owner == "" {
+		host, _ := os.Hostname()
+		c.Owner = host + ":" + strconv.Itoa(os.Getpid())
+	}
+	if c.TTL <= 0 {
+		c.TTL = 30 * time.Second
+	}
+	if c.Clock == nil {
+		c.Clock = time.Now
+	}
+}
+
+// Locker acquires leases.
+type Locker struct {
+	bucket    bfs.Bucket
+	exclusive exclusiveBucket
+	config    *Config
+}
+
+// exclusiveBucket is implemented by buckets which support exclusive creation.
+type exclusiveBucket interface {
+	CreateNew(context.Context, string, *bfs.WriteOptions) (bfs.Writer, error)
+}
+
+// New returns a new Locker which stores lock objects in bucket. It returns
+// bfs.ErrNoExclusiveCreate if the bucket does not support exclusive creation.
+func New(bucket bfs.Bucket, cfg *Config) (*Locker, error) {
+	exclusive, ok := bucket.(exclusiveBucket)
+	if !ok {
+		return nil, bfs.ErrNoExclusiveCreate
+	}
+
+	config := new(Config)
+	if cfg != nil {
+		*config = *cfg
+	}
+	config.norm()
+
+	return &Locker{
+		bucket:    bucket,
+		exclusive: exclusive,
+		config:    config,
+	}, nil
+}
+
+// Acquire acquires a named lease. It returns ErrLocked if the lease is held by
+// another owner and has not expired.
+func (l *Locker) Acquire(ctx context.Context, name string) (*Lease, error) {
+	latest, err := l.latestToken(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var prev *record
+	if latest != 0 {
+		if prev, err = l.read(ctx, name, latest); errors.Is(err, bfs.ErrNotFound) {
+			prev = nil
+		} else if err != nil {
+			return nil, err
+		} else if l.config.Clock().Before(prev.Expires) {
+			return nil, ErrLocked
+		}
+	}
+
+	rec := &record{
+		Owner:   l.config.Owner,
+		Token:   latest + 1,
+		Expires: l.config.Clock().Add(l.config.TTL),
+	}
+	if err := l.write(ctx, l.createNew, name, rec); errors.Is(err, bfs.ErrExists) {
+		return nil, ErrLocked
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Ensure the previous holder has not renewed its lease concurrently.
+	if prev != nil {
+		if cur, err := l.read(ctx, name, latest); err == nil && l.config.Clock().Before(cur.Expires) {
+			_ = l.bucket.Remove(ctx, lockKey(name, rec.Token))
+			return nil, ErrLocked
+		} else if err != nil && !errors.Is(err, bfs.ErrNotFound) {
+			_ = l.bucket.Remove(ctx, lockKey(name, rec.Token))
+			return nil, err
+		}
+	}
+
+	l.cleanup(ctx, name, rec.Token)
+	return &Lease{locker: l, name: name, token: rec.Token, expires: rec.Expires}, nil
+}
+
+// latestToken returns the latest token of a lease or 0 if none exist.
+func (l *Locker) latestToken(ctx context.Context, name string) (int64, error) {
+	iter, err := l.bucket.Glob(ctx, path.Join(name, "*"))
+	if err != nil {
+		return 0, err
+	}
+	defer iter.Close()
+
+	var latest int64
+	for iter.Next() {
+		if token, ok := parseToken(iter.Name()); ok && token > latest {
+			latest = token
+		}
+	}
+	return latest, iter.Error()
+}
+
+// cleanup removes lock objects of previous tokens, errors are ignored.
+func (l *Locker) cleanup(ctx context.Context, name string, token int64) {
+	iter, err := l.bucket.Glob(ctx, path.Join(name, "*"))
+	if err != nil {
+		return
+	}
+	defer iter.Close()
+
+	var keys []string
+	for iter.Next() {
+		if n, ok := parseToken(iter.Name()); ok && n < token {
+			keys = append(keys, iter.Name())
+		}
+	}
+	for _, key := range keys {
+		_ = l.bucket.Remove(ctx, key)
+	}
+}
+
+func (l *Locker) read(ctx context.Context, name string, token int64) (*record, error) {
+	r, err := l.bucket.Open(ctx, lockKey(name, token))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	rec := new(record)
+	if err := json.NewDecoder(r).Decode(rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// createNew creates a lock object exclusively.
+func (l *Locker) createNew(ctx context.Context, name string, opts *bfs.WriteOptions) (bfs.Writer, error) {
+	w, err := l.exclusive.CreateNew(ctx, name, opts)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil, bfs.ErrNoExclusiveCreate
+	}
+	return w, err
+}
+
+func (*Locker) write(ctx context.Context, create bfs.CreateFunc, name string, rec *record) error {
+	w, err := create(ctx, lockKey(name, rec.Token), &bfs.WriteOptions{ContentType: "application/json"})
+	if err != nil {
+		return err
+	}
+	defer w.Discard()
+
+	if err := json.NewEncoder(w).Encode(rec); err != nil {
+		return err
+	}
+	return w.Commit()
+}
+
+// --------------------------------------------------------------------
+
+// Lease is an acquired lease.
+type Lease struct {
+	locker *Locker
+	name   string
+	token  int64
+
+	expires  time.Time
+	released bool
+	mu       sync.Mutex
+}
+
+// Name returns the lease name.
+func (l *Lease) Name() string { return l.name }
+
+// Token returns the fencing token of the lease. Tokens increase
+// monotonically with every acquisition of a named lease.
+func (l *Lease) Token() int64 { return l.token }
+
+// Expires returns the expiry time of the lease.
+func (l *Lease) Expires() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.expires
+}
+
+// Renew extends the lease by the configured TTL. It returns ErrLost if the
+// lease has expired, was released or has been taken over.
+func (l *Lease) Renew(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.released || !l.locker.config.Clock().Before(l.expires) {
+		return ErrLost
+	}
+
+	rec := &record{
+		Owner:   l.locker.config.Owner,
+		Token:   l.token,
+		Expires: l.locker.config.Clock().Add(l.locker.config.TTL),
+	}
+	if err := l.locker.write(ctx, l.locker.bucket.Create, l.name, rec); err != nil {
+		return err
+	}
+
+	// Check for takeovers after writing, see Locker.Acquire.
+	if latest, err := l.locker.latestToken(ctx, l.name); err != nil {
+		return err
+	} else if latest != l.token {
+		return ErrLost
+	}
+
+	l.expires = rec.Expires
+	return nil
+}
+
+// Release releases the lease by marking it as expired. It returns ErrLost if
+// the lease has already expired or has been released.
+func (l *Lease) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.locker.config.Clock()
+	if l.released || !now.Before(l.expires) {
+		return ErrLost
+	}
+	l.released = true
+
+	// Keep the lock object, so tokens of subsequent leases increase.
+	return l.locker.write(ctx, l.locker.bucket.Create, l.name, &record{
+		Owner:   l.locker.config.Owner,
+		Token:   l.token,
+		Expires: now,
+	})
+}
+
+// --------------------------------------------------------------------
+
+type record struct {
+	Owner   string    `json:"owner"`
+	Token   int64     `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+func lockKey(name string, token int64) string {
+	return path.Join(name, fmt.Sprintf("%020d", token))
+}
+
+func parseToken(key string) (int64, bool) {
+	token, err := strconv.ParseInt(path.Base(key), 10, 64)
+	return token, err == nil && token > 0
+}
@@ -0,0 +1,219 @@
+package bfslock_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bsm/bfs"
+	"github.com/bsm/bfs/bfsfs"
+	"github.com/bsm/bfs/bfslock"
+)
+
+func TestLocker(t *testing.T) {
+	ctx := t.Context()
+
+	var mu sync.Mutex
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	advance := func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(d)
+	}
+
+	newLockers := func(t *testing.T) (*bfslock.Locker, *bfslock.Locker) {
+		t.Helper()
+
+		bucket := bfs.NewInMem()
+		a := newLocker(t, bucket, &bfslock.Config{Owner: "a", TTL: time.Minute, Clock: clock})
+		b := newLocker(t, bucket, &bfslock.Config{Owner: "b", TTL: time.Minute, Clock: clock})
+		return a, b
+	}
+
+	t.Run("acquires", func(t *testing.T) {
+		a, b := newLockers(t)
+
+		lease, err := a.Acquire(ctx, "job")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := int64(1), lease.Token(); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := clock().Add(time.Minute), lease.Expires(); !exp.Equal(got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		if _, err := b.Acquire(ctx, "job"); err != bfslock.ErrLocked {
+			t.Errorf("Expected %v, got %v", bfslock.ErrLocked, err)
+		}
+		if _, err := a.Acquire(ctx, "job"); err != bfslock.ErrLocked {
+			t.Errorf("Expected %v, got %v", bfslock.ErrLocked, err)
+		}
+		if _, err := b.Acquire(ctx, "other"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+	})
+
+	t.Run("renews", func(t *testing.T) {
+		a, b := newLockers(t)
+
+		lease, err := a.Acquire(ctx, "job")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		advance(50 * time.Second)
+		if err := lease.Renew(ctx); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := clock().Add(time.Minute), lease.Expires(); !exp.Equal(got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		advance(50 * time.Second)
+		if _, err := b.Acquire(ctx, "job"); err != bfslock.ErrLocked {
+			t.Errorf("Expected %v, got %v", bfslock.ErrLocked, err)
+		}
+	})
+
+	t.Run("releases", func(t *testing.T) {
+		a, b := newLockers(t)
+
+		lease, err := a.Acquire(ctx, "job")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := lease.Release(ctx); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := lease.Release(ctx); err != bfslock.ErrLost {
+			t.Errorf("Expected %v, got %v", bfslock.ErrLost, err)
+		}
+		if err := lease.Renew(ctx); err != bfslock.ErrLost {
+			t.Errorf("Expected %v, got %v", bfslock.ErrLost, err)
+		}
+
+		next, err := b.Acquire(ctx, "job")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := int64(2), next.Token(); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("takes over expired leases", func(t *testing.T) {
+		a, b := newLockers(t)
+
+		lease, err := a.Acquire(ctx, "job")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		advance(time.Minute)
+		next, err := b.Acquire(ctx, "job")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := int64(2), next.Token(); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		if err := lease.Renew(ctx); err != bfslock.ErrLost {
+			t.Errorf("Expected %v, got %v", bfslock.ErrLost, err)
+		}
+		if err := lease.Release(ctx); err != bfslock.ErrLost {
+			t.Errorf("Expected %v, got %v", bfslock.ErrLost, err)
+		}
+	})
+
+	t.Run("detects takeovers on renew", func(t *testing.T) {
+		bucket := bfs.NewInMem()
+		a := newLocker(t, bucket, &bfslock.Config{Owner: "a", TTL: time.Minute, Clock: clock})
+		b := newLocker(t, bucket, &bfslock.Config{Owner: "b", TTL: 30 * time.Second, Clock: clock})
+
+		lease, err := a.Acquire(ctx, "job")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		// simulate a clock skew, where b considers the lease expired
+		advance(2 * time.Minute)
+		if _, err := b.Acquire(ctx, "job"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		advance(-90 * time.Second)
+
+		if err := lease.Renew(ctx); err != bfslock.ErrLost {
+			t.Errorf("Expected %v, got %v", bfslock.ErrLost, err)
+		}
+	})
+
+	t.Run("acquires exclusively", func(t *testing.T) {
+		for _, bucket := range []bfs.Bucket{
+			bfs.NewInMem(),
+			newFSBucket(t),
+			bfs.Wrap(bfs.NewInMem(), bfs.Middleware{}),
+			bfs.WithRetry(newFSBucket(t), nil),
+		} {
+			var acquired atomic.Int32
+			var wg sync.WaitGroup
+			for range 10 {
+				locker := newLocker(t, bucket, &bfslock.Config{Clock: clock})
+				wg.Go(func() {
+					if _, err := locker.Acquire(ctx, "job"); err == nil {
+						acquired.Add(1)
+					} else if !errors.Is(err, bfslock.ErrLocked) {
+						t.Error("Unexpected error", err)
+					}
+				})
+			}
+			wg.Wait()
+
+			if exp, got := int32(1), acquired.Load(); exp != got {
+				t.Errorf("Expected %v, got %v", exp, got)
+			}
+		}
+	})
+}
+
+func TestNew(t *testing.T) {
+	if _, err := bfslock.New(struct{ bfs.Bucket }{bfs.NewInMem()}, nil); !errors.Is(err, bfs.ErrNoExclusiveCreate) {
+		t.Errorf("Expected %v, got %v", bfs.ErrNoExclusiveCreate, err)
+	}
+
+	// detected on acquire when wrapped
+	locker := newLocker(t, bfs.Wrap(struct{ bfs.Bucket }{bfs.NewInMem()}, bfs.Middleware{}), nil)
+	if _, err := locker.Acquire(t.Context(), "job"); !errors.Is(err, bfs.ErrNoExclusiveCreate) {
+		t.Errorf("Expected %v, got %v", bfs.ErrNoExclusiveCreate, err)
+	}
+}
+
+func newLocker(t *testing.T, bucket bfs.Bucket, cfg *bfslock.Config) *bfslock.Locker {
+	t.Helper()
+
+	locker, err := bfslock.New(bucket, cfg)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	return locker
+}
+
+func newFSBucket(t *testing.T) bfs.Bucket {
+	t.Helper()
+
+	bucket, err := bfsfs.New(t.TempDir(), "")
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	t.Cleanup(func() { _ = bucket.Close() })
+	return bucket
+}
@@ -0,0 +1,77 @@
+package bfslog_test
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/bsm/bfs"
+	"github.com/bsm/bfs/bfslog"
+	"github.com/bsm/bfs/bfstest"
+)
+
+func TestWrap(t *testing.T) {
+	t.Run("lint", func(t *testing.T) {
+		logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		bucket := bfslog.Wrap(bfs.NewInMem(), &bfslog.Config{Logger: logger})
+		bfstest.Common(t, bucket, bfstest.Supports{Metadata: true})
+	})
+
+	t.Run("logs", func(t *testing.T) {
+		ctx := t.Context()
+		buf := new(bytes.Buffer)
+		logger := slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{
+			Level: slog.LevelDebug,
+			ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.TimeKey || a.Key == "duration" {
+					return slog.Attr{}
+				}
+				return a
+			},
+		}))
+		bucket := bfslog.Wrap(bfs.NewInMem(), &bfslog.Config{
+			Logger: logger,
+			Levels: map[bfslog.Op]slog.Level{bfslog.OpCommit: slog.LevelInfo},
+		})
+
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		r, err := bucket.Open(ctx, "a.txt")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if _, err := io.ReadAll(r); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := r.Close(); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if _, err := bucket.Head(ctx, "missing.txt"); err == nil {
+			t.Fatal("Expected error")
+		}
+		iter, err := bucket.Glob(ctx, "*")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		for iter.Next() {
+		}
+		if err := iter.Close(); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		exp := []string{
+			`level=DEBUG msg=bfs op=create name=a.txt`,
+			`level=INFO msg=bfs op=commit name=a.txt bytes_written=4`,
+			`level=DEBUG msg=bfs op=open name=a.txt bytes_read=4`,
+			`level=DEBUG msg=bfs op=head name=missing.txt error_class=not_found error="bfs: object not found"`,
+			`level=DEBUG msg=bfs op=glob pattern=* entries=1`,
+		}
+		got := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if strings.Join(exp, "\n") != strings.Join(got, "\n") {
+			t.Errorf("Expected:\n%s\ngot:\n%s", strings.Join(exp, "\n"), strings.Join(got, "\n"))
+		}
+	})
+}
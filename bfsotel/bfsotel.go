@@ -0,0 +1,362 @@
+// Package bfsotel instruments buckets with OpenTelemetry tracing and metrics.
+//
+// Every bucket operation is recorded as a span. Spans of Open and Create
+// cover the whole lifetime of the returned reader/writer, i.e. until Close
+// and Commit/Discard respectively, and Glob spans last until the iterator
+// is closed. Additionally, operation latencies, transferred bytes and
+// errors are recorded as metrics.
+//
+//	import (
+//	  "github.com/bsm/bfs"
+//	  "github.com/bsm/bfs/bfsotel"
+//	)
+//
+//	func main() {
+//	  ctx := context.TODO()
+//	  b, _ := bfs.Connect(ctx, "s3://bucket/a")
+//	  b, _ = bfsotel.Wrap(b, &bfsotel.Config{Scheme: "s3", Bucket: "bucket"})
+//	  ...
+//	}
+package bfsotel
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bsm/bfs"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName is the name of the tracer and meter.
+const InstrumentationName = "github.com/bsm/bfs/bfsotel"
+
+// Attribute keys.
+const (
+	SchemeKey       = attribute.Key("bfs.scheme")
+	BucketKey       = attribute.Key("bfs.bucket")
+	OperationKey    = attribute.Key("bfs.operation")
+	ObjectKey       = attribute.Key("bfs.object")
+	DestinationKey  = attribute.Key("bfs.destination")
+	PatternKey      = attribute.Key("bfs.pattern")
+	BytesReadKey    = attribute.Key("bfs.bytes_read")
+	BytesWrittenKey = attribute.Key("bfs.bytes_written")
+	EntriesKey      = attribute.Key("bfs.entries")
+	ErrorTypeKey    = attribute.Key("error.type")
+)
+
+// Config is passed to Wrap to configure instrumentation.
+type Config struct {
+	// TracerProvider to use, defaults to the global provider.
+	TracerProvider trace.TracerProvider
+	// MeterProvider to use, defaults to the global provider.
+	MeterProvider metric.MeterProvider
+	// Scheme of the instrumented bucket, e.g. "s3".
+	Scheme string
+	// Name of the instrumented bucket.
+	Bucket string
+}
+
+func (c *Config) norm() error {
+	if c.TracerProvider == nil {
+		c.TracerProvider = otel.GetTracerProvider()
+	}
+	if c.MeterProvider == nil {
+		c.MeterProvider = otel.GetMeterProvider()
+	}
+	return nil
+}
+
+// Wrap instruments a bucket.
+func Wrap(bucket bfs.Bucket, cfg *Config) (bfs.Bucket, error) {
+	mw, err := Middleware(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return bfs.Wrap(bucket, mw), nil
+}
+
+// Middleware returns a bfs.Middleware which instruments bucket operations.
+func Middleware(cfg *Config) (bfs.Middleware, error) {
+	config := new(Config)
+	if cfg != nil {
+		*config = *cfg
+	}
+	if err := config.norm(); err != nil {
+		return bfs.Middleware{}, err
+	}
+
+	in, err := newInstrumentation(config)
+	if err != nil {
+		return bfs.Middleware{}, err
+	}
+
+	return bfs.Middleware{
+		Glob:      in.glob,
+		Head:      in.head,
+		Open:      in.open,
+		Create:    in.create,
+		Remove:    in.remove,
+		Copy:      in.copy,
+		RemoveAll: in.removeAll,
+	}, nil
+}
+
+// --------------------------------------------------------------------
+
+type instrumentation struct {
+	tracer trace.Tracer
+	attrs  []attribute.KeyValue
+
+	duration     metric.Float64Histogram
+	bytesRead    metric.Int64Counter
+	bytesWritten metric.Int64Counter
+	errors       metric.Int64Counter
+}
+
+func newInstrumentation(c *Config) (*instrumentation, error) {
+	meter := c.MeterProvider.Meter(InstrumentationName)
+
+	duration, err := meter.Float64Histogram("bfs.operation.duration",
+		metric.WithDescription("Duration of bucket operations."),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+
+	bytesRead, err := meter.Int64Counter("bfs.bytes_read",
+		metric.WithDescription("Number of bytes read from objects."),
+		metric.WithUnit("By"))
+	if err != nil {
+		return nil, err
+	}
+
+	bytesWritten, err := meter.Int64Counter("bfs.bytes_written",
+		metric.WithDescription("Number of bytes written to objects."),
+		metric.WithUnit("By"))
+	if err != nil {
+		return nil, err
+	}
+
+	errs, err := meter.Int64Counter("bfs.errors",
+		metric.WithDescription("Number of failed bucket operations."),
+		metric.WithUnit("{error}"))
+	if err != nil {
+		return nil, err
+	}
+
+	var attrs []attribute.KeyValue
+	if c.Scheme != "" {
+		attrs = append(attrs, SchemeKey.String(c.Scheme))
+	}
+	if c.Bucket != "" {
+		attrs = append(attrs, BucketKey.String(c.Bucket))
+	}
+
+	return &instrumentation{
+		tracer:       c.TracerProvider.Tracer(InstrumentationName),
+		attrs:        attrs,
+		duration:     duration,
+		bytesRead:    bytesRead,
+		bytesWritten: bytesWritten,
+		errors:       errs,
+	}, nil
+}
+
+// start starts a new operation.
+func (in *instrumentation) start(ctx context.Context, op string, attrs ...attribute.KeyValue) (context.Context, *operation) {
+	attrs = append(attrs, in.attrs...)
+	ctx, span := in.tracer.Start(ctx, "bfs."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...))
+
+	return ctx, &operation{
+		ctx:   ctx,
+		in:    in,
+		span:  span,
+		name:  op,
+		start: time.Now(),
+	}
+}
+
+func (in *instrumentation) glob(ctx context.Context, pattern string, next bfs.GlobFunc) (bfs.Iterator, error) {
+	ctx, op := in.start(ctx, "Glob", PatternKey.String(pattern))
+	iter, err := next(ctx, pattern)
+	if err != nil {
+		op.end(err)
+		return nil, err
+	}
+	return &iterator{Iterator: iter, op: op}, nil
+}
+
+func (in *instrumentation) head(ctx context.Context, name string, next bfs.HeadFunc) (*bfs.MetaInfo, error) {
+	ctx, op := in.start(ctx, "Head", ObjectKey.String(name))
+	info, err := next(ctx, name)
+	op.end(err)
+	return info, err
+}
+
+func (in *instrumentation) open(ctx context.Context, name string, next bfs.OpenFunc) (bfs.Reader, error) {
+	ctx, op := in.start(ctx, "Open", ObjectKey.String(name))
+	r, err := next(ctx, name)
+	if err != nil {
+		op.end(err)
+		return nil, err
+	}
+	return &reader{Reader: r, op: op}, nil
+}
+
+func (in *instrumentation) create(ctx context.Context, name string, opts *bfs.WriteOptions, next bfs.CreateFunc) (bfs.Writer, error) {
+	ctx, op := in.start(ctx, "Create", ObjectKey.String(name))
+	w, err := next(ctx, name, opts)
+	if err != nil {
+		op.end(err)
+		return nil, err
+	}
+	return &writer{Writer: w, op: op}, nil
+}
+
+func (in *instrumentation) remove(ctx context.Context, name string, next bfs.RemoveFunc) error {
+	ctx, op := in.start(ctx, "Remove", ObjectKey.String(name))
+	err := next(ctx, name)
+	op.end(err)
+	return err
+}
+
+func (in *instrumentation) copy(ctx context.Context, src, dst string, next bfs.CopyFunc) error {
+	ctx, op := in.start(ctx, "Copy", ObjectKey.String(src), DestinationKey.String(dst))
+	err := next(ctx, src, dst)
+	op.end(err)
+	return err
+}
+
+func (in *instrumentation) removeAll(ctx context.Context, pattern string, next bfs.RemoveAllFunc) error {
+	ctx, op := in.start(ctx, "RemoveAll", PatternKey.String(pattern))
+	err := next(ctx, pattern)
+	op.end(err)
+	return err
+}
+
+// --------------------------------------------------------------------
+
+// operation is an instrumented operation in progress.
+type operation struct {
+	ctx   context.Context
+	in    *instrumentation
+	span  trace.Span
+	name  string
+	start time.Time
+}
+
+// end ends the operation, extra attributes are only recorded on the span.
+func (op *operation) end(err error, extra ...attribute.KeyValue) {
+	attrs := append([]attribute.KeyValue{OperationKey.String(op.name)}, op.in.attrs...)
+
+	switch {
+	case err == nil:
+	case errors.Is(err, errors.ErrUnsupported):
+		// extension is not supported, helpers will fall back
+		op.span.SetAttributes(ErrorTypeKey.String("unsupported"))
+	default:
+		errType := "other"
+		if errors.Is(err, bfs.ErrNotFound) {
+			errType = "not_found"
+		}
+		op.span.RecordError(err)
+		op.span.SetStatus(codes.Error, err.Error())
+		op.span.SetAttributes(ErrorTypeKey.String(errType))
+		op.in.errors.Add(op.ctx, 1, metric.WithAttributes(append(attrs, ErrorTypeKey.String(errType))...))
+	}
+
+	op.in.duration.Record(op.ctx, time.Since(op.start).Seconds(), metric.WithAttributes(attrs...))
+	op.span.SetAttributes(extra...)
+	op.span.End()
+}
+
+type reader struct {
+	bfs.Reader
+	op *operation
+	n  int64
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+func (r *reader) Close() error {
+	err := r.Reader.Close()
+	if r.op != nil {
+		r.op.in.bytesRead.Add(r.op.ctx, r.n, metric.WithAttributes(r.op.in.attrs...))
+		r.op.end(err, BytesReadKey.Int64(r.n))
+		r.op = nil
+	}
+	return err
+}
+
+type writer struct {
+	bfs.Writer
+	op *operation
+	n  int64
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+func (w *writer) Commit() error {
+	err := w.Writer.Commit()
+	if w.op != nil {
+		if err == nil {
+			w.op.in.bytesWritten.Add(w.op.ctx, w.n, metric.WithAttributes(w.op.in.attrs...))
+		}
+		w.op.end(err, BytesWrittenKey.Int64(w.n), attribute.Bool("bfs.committed", err == nil))
+		w.op = nil
+	}
+	return err
+}
+
+func (w *writer) Discard() error {
+	err := w.Writer.Discard()
+	if w.op != nil {
+		w.op.end(nil, BytesWrittenKey.Int64(0), attribute.Bool("bfs.committed", false))
+		w.op = nil
+	}
+	return err
+}
+
+type iterator struct {
+	bfs.Iterator
+	op *operation
+	n  int64
+}
+
+func (i *iterator) Next() bool {
+	ok := i.Iterator.Next()
+	if ok {
+		i.n++
+	}
+	return ok
+}
+
+func (i *iterator) Close() error {
+	err := i.Iterator.Close()
+	if i.op != nil {
+		// record iteration errors on the span and errors metric, but only
+		// return the Close error
+		opErr := err
+		if opErr == nil {
+			opErr = i.Iterator.Error()
+		}
+		i.op.end(opErr, EntriesKey.Int64(i.n))
+		i.op = nil
+	}
+	return err
+}
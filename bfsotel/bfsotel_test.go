@@ -0,0 +1,184 @@
+package bfsotel_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/bsm/bfs"
+	"github.com/bsm/bfs/bfsotel"
+	"github.com/bsm/bfs/bfstest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestWrap(t *testing.T) {
+	t.Run("lint", func(t *testing.T) {
+		bucket, err := bfsotel.Wrap(bfs.NewInMem(), &bfsotel.Config{
+			TracerProvider: sdktrace.NewTracerProvider(),
+			MeterProvider:  sdkmetric.NewMeterProvider(),
+		})
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		bfstest.Common(t, bucket, bfstest.Supports{Metadata: true})
+	})
+
+	t.Run("instruments", func(t *testing.T) {
+		ctx := t.Context()
+		spans := tracetest.NewInMemoryExporter()
+		metrics := sdkmetric.NewManualReader()
+
+		bucket, err := bfsotel.Wrap(bfs.NewInMem(), &bfsotel.Config{
+			TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans)),
+			MeterProvider:  sdkmetric.NewMeterProvider(sdkmetric.WithReader(metrics)),
+			Scheme:         "mem",
+			Bucket:         "test",
+		})
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		r, err := bucket.Open(ctx, "a.txt")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if _, err := io.ReadAll(r); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := r.Close(); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if _, err := bucket.Head(ctx, "missing.txt"); !errors.Is(err, bfs.ErrNotFound) {
+			t.Errorf("Expected %v, got %v", bfs.ErrNotFound, err)
+		}
+
+		// check spans
+		var names []string
+		for _, span := range spans.GetSpans() {
+			names = append(names, span.Name)
+		}
+		if exp := []string{"bfs.Create", "bfs.Open", "bfs.Head"}; !reflect.DeepEqual(exp, names) {
+			t.Fatalf("Expected %v, got %v", exp, names)
+		}
+
+		stubs := spans.GetSpans()
+		if exp, got := attribute.Int64Value(4), spanAttr(stubs[0], bfsotel.BytesWrittenKey); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := attribute.Int64Value(4), spanAttr(stubs[1], bfsotel.BytesReadKey); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := attribute.StringValue("mem"), spanAttr(stubs[1], bfsotel.SchemeKey); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := codes.Error, stubs[2].Status.Code; exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := attribute.StringValue("not_found"), spanAttr(stubs[2], bfsotel.ErrorTypeKey); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		// check metrics
+		var rm metricdata.ResourceMetrics
+		if err := metrics.Collect(ctx, &rm); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		sums := make(map[string]int64)
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				switch data := m.Data.(type) {
+				case metricdata.Sum[int64]:
+					for _, dp := range data.DataPoints {
+						sums[m.Name] += dp.Value
+					}
+				case metricdata.Histogram[float64]:
+					for _, dp := range data.DataPoints {
+						sums[m.Name] += int64(dp.Count)
+					}
+				}
+			}
+		}
+		exp := map[string]int64{
+			"bfs.operation.duration": 3,
+			"bfs.bytes_read":         4,
+			"bfs.bytes_written":      4,
+			"bfs.errors":             1,
+		}
+		if !reflect.DeepEqual(exp, sums) {
+			t.Errorf("Expected %v, got %v", exp, sums)
+		}
+	})
+
+	t.Run("keeps iterator errors", func(t *testing.T) {
+		ctx := t.Context()
+		spans := tracetest.NewInMemoryExporter()
+		errBroken := errors.New("broken")
+
+		base := bfs.Wrap(bfs.NewInMem(), bfs.Middleware{
+			Glob: func(ctx context.Context, pattern string, next bfs.GlobFunc) (bfs.Iterator, error) {
+				iter, err := next(ctx, pattern)
+				if err != nil {
+					return nil, err
+				}
+				return &failingIterator{Iterator: iter, err: errBroken}, nil
+			},
+		})
+		bucket, err := bfsotel.Wrap(base, &bfsotel.Config{
+			TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans)),
+			MeterProvider:  sdkmetric.NewMeterProvider(),
+		})
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		iter, err := bucket.Glob(ctx, "*")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		for iter.Next() {
+		}
+		if err := iter.Error(); err != errBroken {
+			t.Errorf("Expected %v, got %v", errBroken, err)
+		}
+		if err := iter.Close(); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		stubs := spans.GetSpans()
+		if exp, got := 1, len(stubs); exp != got {
+			t.Fatalf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := codes.Error, stubs[0].Status.Code; exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+}
+
+type failingIterator struct {
+	bfs.Iterator
+
+	err error
+}
+
+func (i *failingIterator) Next() bool   { return false }
+func (i *failingIterator) Error() error { return i.err }
+
+func spanAttr(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
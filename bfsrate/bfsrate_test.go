@@ -0,0 +1,142 @@
+package bfsrate_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/bsm/bfs"
+	"github.com/bsm/bfs/bfsrate"
+	"github.com/bsm/bfs/bfstest"
+)
+
+func TestWrap(t *testing.T) {
+	t.Run("lint", func(t *testing.T) {
+		bucket := bfsrate.Wrap(bfs.NewInMem(), &bfsrate.Limits{
+			Ops:   bfsrate.NewLimiter(10000, 100),
+			Read:  bfsrate.NewLimiter(1<<30, 1<<20),
+			Write: bfsrate.NewLimiter(1<<30, 1<<20),
+		})
+		bfstest.Common(t, bucket, bfstest.Supports{Metadata: true})
+	})
+
+	t.Run("limits ops", func(t *testing.T) {
+		ctx := t.Context()
+		limits := &bfsrate.Limits{
+			PerOp: map[bfsrate.Op]*bfsrate.Limiter{
+				bfsrate.OpHead: bfsrate.NewLimiter(50, 1),
+			},
+		}
+
+		// share limits across buckets
+		a := bfsrate.Wrap(bfs.NewInMem(), limits)
+		b := bfsrate.Wrap(bfs.NewInMem(), limits)
+
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			_, _ = a.Head(ctx, "x")
+			_, _ = b.Head(ctx, "x")
+		}
+		if exp, got := 90*time.Millisecond, time.Since(start); got < exp {
+			t.Errorf("Expected at least %v, got %v", exp, got)
+		}
+
+		// other ops are not limited
+		start = time.Now()
+		for i := 0; i < 10; i++ {
+			_ = a.Remove(ctx, "x")
+		}
+		if exp, got := 20*time.Millisecond, time.Since(start); got > exp {
+			t.Errorf("Expected at most %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("limits throughput", func(t *testing.T) {
+		ctx := t.Context()
+		bucket := bfsrate.Wrap(bfs.NewInMem(), &bfsrate.Limits{
+			Read:  bfsrate.NewLimiter(10000, 1000),
+			Write: bfsrate.NewLimiter(10000, 1000),
+		})
+		data := bytes.Repeat([]byte("x"), 2000)
+
+		start := time.Now()
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", data, nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := 90*time.Millisecond, time.Since(start); got < exp {
+			t.Errorf("Expected at least %v, got %v", exp, got)
+		}
+
+		r, err := bucket.Open(ctx, "a.txt")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		defer r.Close()
+
+		start = time.Now()
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if !bytes.Equal(data, got) {
+			t.Errorf("Expected %d bytes, got %d", len(data), len(got))
+		}
+		if exp, got := 90*time.Millisecond, time.Since(start); got < exp {
+			t.Errorf("Expected at least %v, got %v", exp, got)
+		}
+	})
+}
+
+func TestLimiter(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		if lim := bfsrate.NewLimiter(0, 1); lim != nil {
+			t.Errorf("Expected nil, got %v", lim)
+		}
+
+		var lim *bfsrate.Limiter
+		if err := lim.Wait(t.Context(), 1000); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+	})
+
+	t.Run("bursts", func(t *testing.T) {
+		lim := bfsrate.NewLimiter(20, 5)
+		if exp, got := 5, lim.Burst(); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		start := time.Now()
+		for i := 0; i < 5; i++ {
+			if err := lim.Wait(t.Context(), 1); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+		}
+		if exp, got := 20*time.Millisecond, time.Since(start); got > exp {
+			t.Errorf("Expected at most %v, got %v", exp, got)
+		}
+
+		if err := lim.Wait(t.Context(), 1); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := 40*time.Millisecond, time.Since(start); got < exp {
+			t.Errorf("Expected at least %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("cancels", func(t *testing.T) {
+		lim := bfsrate.NewLimiter(1, 1)
+		if err := lim.Wait(t.Context(), 1); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+		defer cancel()
+
+		if err := lim.Wait(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+		}
+	})
+}
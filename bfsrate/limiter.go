@@ -0,0 +1,106 @@
+package bfsrate
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket rate limiter. It is safe for concurrent use and
+// can be shared across multiple buckets.
+type Limiter struct {
+	rate  float64 // tokens per second
+	burst float64
+
+	tokens float64
+	last   time.Time
+	mu     sync.Mutex
+}
+
+// NewLimiter creates a new limiter which allows events up to rate per second
+// and permits bursts of at most burst tokens. A burst < 1 defaults to
+// the rate (but at least 1). A rate <= 0 returns a nil limiter, which is
+// unlimited.
+func NewLimiter(rate float64, burst int) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = int(math.Max(1, math.Ceil(rate)))
+	}
+	return &Limiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+	}
+}
+
+// Rate returns the rate per second.
+func (l *Limiter) Rate() float64 {
+	if l == nil {
+		return math.Inf(1)
+	}
+	return l.rate
+}
+
+// Burst returns the maximum burst size.
+func (l *Limiter) Burst() int {
+	if l == nil {
+		return math.MaxInt
+	}
+	return int(l.burst)
+}
+
+// Wait blocks until n tokens are available or ctx is done.
+func (l *Limiter) Wait(ctx context.Context, n int) error {
+	if l == nil || n <= 0 {
+		return nil
+	}
+
+	delay := l.reserve(float64(n))
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		l.cancel(float64(n))
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// reserve takes n tokens and returns the time to wait until they are
+// available.
+func (l *Limiter) reserve(n float64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.advance(time.Now())
+	l.tokens -= n
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// cancel returns n reserved tokens.
+func (l *Limiter) cancel(n float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.advance(time.Now())
+	l.tokens = math.Min(l.tokens+n, l.burst)
+}
+
+func (l *Limiter) advance(now time.Time) {
+	if !l.last.IsZero() {
+		l.tokens = math.Min(l.tokens+now.Sub(l.last).Seconds()*l.rate, l.burst)
+	}
+	l.last = now
+}
@@ -223,9 +223,19 @@ func (b *bucket) Glob(ctx context.Context, pattern string) (bfs.Iterator, error)
 
 // Head implements bfs.Bucket.
 func (b *bucket) Head(ctx context.Context, name string) (*bfs.MetaInfo, error) {
+	return b.head(ctx, name, "")
+}
+
+// HeadVersion implements bfs.Versioned.
+func (b *bucket) HeadVersion(ctx context.Context, name, versionID string) (*bfs.MetaInfo, error) {
+	return b.head(ctx, name, versionID)
+}
+
+func (b *bucket) head(ctx context.Context, name, versionID string) (*bfs.MetaInfo, error) {
 	resp, err := b.HeadObject(ctx, &s3.HeadObjectInput{
-		Bucket: aws.String(b.bucket),
-		Key:    aws.String(b.withPrefix(name)),
+		Bucket:    aws.String(b.bucket),
+		Key:       aws.String(b.withPrefix(name)),
+		VersionId: strPresence(versionID),
 	})
 	if err != nil {
 		return nil, normError(err)
@@ -242,9 +252,19 @@ func (b *bucket) Head(ctx context.Context, name string) (*bfs.MetaInfo, error) {
 
 // Open implements bfs.Bucket.
 func (b *bucket) Open(ctx context.Context, name string) (bfs.Reader, error) {
+	return b.open(ctx, name, "")
+}
+
+// OpenVersion implements bfs.Versioned.
+func (b *bucket) OpenVersion(ctx context.Context, name, versionID string) (bfs.Reader, error) {
+	return b.open(ctx, name, versionID)
+}
+
+func (b *bucket) open(ctx context.Context, name, versionID string) (bfs.Reader, error) {
 	resp, err := b.GetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(b.bucket),
-		Key:    aws.String(b.withPrefix(name)),
+		Bucket:    aws.String(b.bucket),
+		Key:       aws.String(b.withPrefix(name)),
+		VersionId: strPresence(versionID),
 	})
 	if err != nil {
 		return nil, normError(err)
@@ -271,18 +291,162 @@ func (b *bucket) Create(ctx context.Context, name string, opts *bfs.WriteOptions
 	}, nil
 }
 
+// CreateNew supports exclusive creation of objects using conditional writes.
+// Commits fail with bfs.ErrExists if the object already exists.
+func (b *bucket) CreateNew(ctx context.Context, name string, opts *bfs.WriteOptions) (bfs.Writer, error) {
+	w, err := b.Create(ctx, name, opts)
+	if err != nil {
+		return nil, err
+	}
+	w.(*writer).exclusive = true
+	return w, nil
+}
+
 // Remove implements bfs.Bucket.
 func (b *bucket) Remove(ctx context.Context, name string) error {
+	return b.remove(ctx, name, "")
+}
+
+// RemoveVersion implements bfs.Versioned.
+func (b *bucket) RemoveVersion(ctx context.Context, name, versionID string) error {
+	return b.remove(ctx, name, versionID)
+}
+
+func (b *bucket) remove(ctx context.Context, name, versionID string) error {
 	_, err := b.DeleteObject(ctx, &s3.DeleteObjectInput{
-		Bucket: aws.String(b.bucket),
-		Key:    aws.String(b.withPrefix(name)),
+		Bucket:    aws.String(b.bucket),
+		Key:       aws.String(b.withPrefix(name)),
+		VersionId: strPresence(versionID),
 	})
 	return normError(err)
 }
 
+// ListVersions implements bfs.Versioned. Versioning must be enabled
+// on the bucket, otherwise only a single "null" version is returned.
+func (b *bucket) ListVersions(ctx context.Context, name string) ([]*bfs.VersionInfo, error) {
+	key := b.withPrefix(name)
+	p := s3.NewListObjectVersionsPaginator(b, &s3.ListObjectVersionsInput{
+		Bucket: aws.String(b.bucket),
+		Prefix: aws.String(key),
+	})
+
+	// versions and delete markers are listed separately,
+	// each in listing order, i.e. newest first
+	var versions, markers []*bfs.VersionInfo
+	for p.HasMorePages() {
+		res, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, normError(err)
+		}
+
+		for _, v := range res.Versions {
+			if aws.ToString(v.Key) == key {
+				versions = append(versions, &bfs.VersionInfo{
+					MetaInfo: bfs.MetaInfo{
+						Name:    name,
+						Size:    aws.ToInt64(v.Size),
+						ModTime: aws.ToTime(v.LastModified),
+					},
+					VersionID: aws.ToString(v.VersionId),
+					IsLatest:  aws.ToBool(v.IsLatest),
+				})
+			}
+		}
+		for _, m := range res.DeleteMarkers {
+			if aws.ToString(m.Key) == key {
+				markers = append(markers, &bfs.VersionInfo{
+					MetaInfo: bfs.MetaInfo{
+						Name:    name,
+						ModTime: aws.ToTime(m.LastModified),
+					},
+					VersionID:      aws.ToString(m.VersionId),
+					IsLatest:       aws.ToBool(m.IsLatest),
+					IsDeleteMarker: true,
+				})
+			}
+		}
+	}
+	if len(versions) == 0 && len(markers) == 0 {
+		return nil, bfs.ErrNotFound
+	}
+
+	merged := make([]*bfs.VersionInfo, 0, len(versions)+len(markers))
+	for len(versions) != 0 && len(markers) != 0 {
+		var prev *bfs.VersionInfo
+		if n := len(merged); n != 0 {
+			prev = merged[n-1]
+		}
+
+		markerFirst, err := b.listsBefore(ctx, key, prev, markers[0], versions[0])
+		if err != nil {
+			return nil, err
+		}
+		if markerFirst {
+			merged, markers = append(merged, markers[0]), markers[1:]
+		} else {
+			merged, versions = append(merged, versions[0]), versions[1:]
+		}
+	}
+	merged = append(merged, versions...)
+	merged = append(merged, markers...)
+	return merged, nil
+}
+
+// listsBefore reports whether entry x is listed before entry y, where prev is
+// the entry which is listed immediately before either of them (if any).
+// LastModified has a resolution of seconds only, ties are resolved by listing
+// the single entry which follows prev.
+func (b *bucket) listsBefore(ctx context.Context, key string, prev, x, y *bfs.VersionInfo) (bool, error) {
+	switch {
+	case x.IsLatest != y.IsLatest:
+		return x.IsLatest, nil
+	case !x.ModTime.Equal(y.ModTime):
+		return x.ModTime.After(y.ModTime), nil
+	}
+
+	input := &s3.ListObjectVersionsInput{
+		Bucket:  aws.String(b.bucket),
+		Prefix:  aws.String(key),
+		MaxKeys: aws.Int32(1),
+	}
+	if prev != nil {
+		input.KeyMarker = aws.String(key)
+		input.VersionIdMarker = aws.String(prev.VersionID)
+	}
+	res, err := b.ListObjectVersions(ctx, input)
+	if err != nil {
+		return false, normError(err)
+	}
+
+	for _, v := range res.Versions {
+		if aws.ToString(v.VersionId) == x.VersionID {
+			return true, nil
+		}
+	}
+	for _, m := range res.DeleteMarkers {
+		if aws.ToString(m.VersionId) == x.VersionID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RestoreVersion restores a specific version as the current version of an
+// object by copying it server-side.
+func (b *bucket) RestoreVersion(ctx context.Context, name, versionID string) error {
+	return b.copy(ctx, name, versionID, name)
+}
+
 // Copy supports copying of objects within the bucket.
 func (b *bucket) Copy(ctx context.Context, src, dst string) error {
+	return b.copy(ctx, src, "", dst)
+}
+
+func (b *bucket) copy(ctx context.Context, src, versionID, dst string) error {
 	source := path.Join("/", b.bucket, b.withPrefix(src))
+	if versionID != "" {
+		source += "?versionId=" + url.QueryEscape(versionID)
+	}
 	_, err := b.CopyObject(ctx, &s3.CopyObjectInput{
 		Bucket:               aws.String(b.bucket),
 		CopySource:           aws.String(source),
@@ -307,6 +471,7 @@ type writer struct {
 	name   string
 	opts   *bfs.WriteOptions
 
+	exclusive bool
 	closeOnce sync.Once
 }
 
@@ -343,6 +508,11 @@ func (w *writer) Commit() error {
 		}
 		defer file.Close()
 
+		var ifNoneMatch *string
+		if w.exclusive {
+			ifNoneMatch = aws.String("*")
+		}
+
 		// Upload file
 		_, err = w.bucket.uploader.Upload(w.ctx, &s3.PutObjectInput{
 			Bucket:               aws.String(w.bucket.bucket),
@@ -353,6 +523,7 @@ func (w *writer) Commit() error {
 			ACL:                  types.ObjectCannedACL(w.bucket.config.ACL),
 			GrantFullControl:     strPresence(w.bucket.config.GrantFullControl),
 			ServerSideEncryption: types.ServerSideEncryption(w.bucket.config.SSE),
+			IfNoneMatch:          ifNoneMatch,
 		})
 	})
 
@@ -366,8 +537,10 @@ func normError(err error) error {
 		var apiErr smithy.APIError
 		if errors.As(err, &apiErr) {
 			switch apiErr.ErrorCode() {
-			case "NotFound", "NoSuchKey", "NoSuchBucket":
+			case "NotFound", "NoSuchKey", "NoSuchBucket", "NoSuchVersion":
 				return bfs.ErrNotFound
+			case "PreconditionFailed", "ConditionalRequestConflict":
+				return bfs.ErrExists
 			}
 		}
 	}
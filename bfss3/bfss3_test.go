@@ -1,13 +1,17 @@
 package bfss3_test
 
 import (
+	"errors"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/bsm/bfs"
 	"github.com/bsm/bfs/bfss3"
-	"github.com/bsm/bfs/testdata/lint"
+	"github.com/bsm/bfs/bfstest"
 )
 
 func Test(t *testing.T) {
@@ -31,10 +35,75 @@ func Test(t *testing.T) {
 	defer bucket.Close()
 
 	t.Run("common", func(t *testing.T) {
-		lint.Common(t, bucket, lint.Supports{ContentType: true, Metadata: true})
+		bfstest.Common(t, bucket, bfstest.Supports{ContentType: true, Metadata: true})
 	})
 
 	t.Run("slow", func(t *testing.T) {
-		lint.Slow(t, bucket, lint.Supports{ContentType: true, Metadata: true})
+		bfstest.Slow(t, bucket, bfstest.Supports{ContentType: true, Metadata: true})
+	})
+
+	t.Run("write once", func(t *testing.T) {
+		ctx := t.Context()
+		name := "once/" + strconv.FormatInt(time.Now().UnixNano(), 10) + ".txt"
+		defer bucket.Remove(ctx, name)
+
+		once := bfs.WriteOnce(bucket)
+		if err := bfs.WriteObject(ctx, once, name, []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bfs.WriteObject(ctx, once, name, []byte("data"), nil); !errors.Is(err, bfs.ErrExists) {
+			t.Errorf("Expected %v, got %v", bfs.ErrExists, err)
+		}
+	})
+
+	t.Run("versioning", func(t *testing.T) {
+		ctx := t.Context()
+		bucket, err := bfss3.New(ctx, "bfs-s3-versioned", &bfss3.Config{AWS: &cfg})
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		defer bucket.Close()
+
+		versioned := bucket.(bfs.Versioned)
+		name := "versions/" + strconv.FormatInt(time.Now().UnixNano(), 10) + ".txt"
+		for _, data := range []string{"v1", "v2"} {
+			if err := bfs.WriteObject(ctx, bucket, name, []byte(data), &bfs.WriteOptions{ContentType: "text/plain"}); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+		}
+		if err := bucket.Remove(ctx, name); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		versions, err := versioned.ListVersions(ctx, name)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := 3, len(versions); exp != got {
+			t.Fatalf("Expected %v, got %v", exp, got)
+		}
+		if !versions[0].IsLatest || !versions[0].IsDeleteMarker {
+			t.Errorf("Expected latest delete marker, got %+v", versions[0])
+		}
+
+		first := versions[2].VersionID
+		if err := bfs.RestoreVersion(ctx, versioned, name, first); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		info, err := bucket.Head(ctx, name)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := "text/plain", info.ContentType; exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		if err := versioned.RemoveVersion(ctx, name, first); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if _, err := versioned.HeadVersion(ctx, name, first); err != bfs.ErrNotFound {
+			t.Errorf("Expected %v, got %v", bfs.ErrNotFound, err)
+		}
 	})
 }
@@ -11,7 +11,7 @@ require (
 	github.com/aws/aws-sdk-go-v2/service/sts v1.38.9
 	github.com/aws/smithy-go v1.23.1
 	github.com/bmatcuk/doublestar/v4 v4.9.1
-	github.com/bsm/bfs v0.12.2
+	github.com/bsm/bfs v0.13.0
 )
 
 require (
@@ -8,3 +8,8 @@ s3_client = boto3.client(
 )
 
 s3_client.create_bucket(Bucket="bfs-s3-test")
+s3_client.create_bucket(Bucket="bfs-s3-versioned")
+s3_client.put_bucket_versioning(
+    Bucket="bfs-s3-versioned",
+    VersioningConfiguration={"Status": "Enabled"},
+)
@@ -1,16 +1,17 @@
 module github.com/bsm/bfs/bfsscp
 
-go 1.24.0
+go 1.25
 
 require (
 	github.com/bmatcuk/doublestar/v3 v3.0.0
-	github.com/bsm/bfs v0.11.9
-	github.com/bsm/ginkgo/v2 v2.1.3
-	github.com/bsm/gomega v1.16.0
+	github.com/bsm/bfs v0.13.0
 	github.com/kr/fs v0.1.0
 	github.com/pkg/sftp v1.13.6
 	go.uber.org/multierr v1.11.0
 	golang.org/x/crypto v0.45.0
 )
 
-require golang.org/x/sys v0.38.0 // indirect
+require (
+	github.com/bmatcuk/doublestar/v4 v4.9.1 // indirect
+	golang.org/x/sys v0.38.0 // indirect
+)
@@ -0,0 +1,587 @@
+// Package bfstest provides utilities for testing bfs.Bucket implementations
+// and code which uses them.
+//
+// Common and Slow run a conformance suite against a bucket. Optional
+// behaviour is enabled through a Supports capability matrix, native Copy and
+// RemoveAll extensions are detected automatically:
+//
+//	func TestBucket(t *testing.T) {
+//	  bucket := mypkg.New(...)
+//	  bfstest.Common(t, bucket, bfstest.Supports{Metadata: true})
+//	}
+//
+// Recorders record all operations on a bucket and provide assertion helpers:
+//
+//	bucket := bfstest.NewRecorder(bfs.NewInMem())
+//	...
+//	bucket.AssertCommitted(t, "a.txt", "b.txt")
+//	bucket.AssertNotCalled(t, bfstest.OpRemove, bfstest.OpRemoveAll)
+//
+// Faulty buckets inject errors, latency and truncated reads into any
+// bucket, e.g. to reproduce a dropped connection in a test:
+//
+//	bucket := bfstest.Faulty(bfs.NewInMem(), &bfstest.Faults{
+//	  Seed:         42,
+//	  ErrorRates:   map[bfstest.Op]float64{bfstest.OpHead: 0.1},
+//	  TruncateRate: 0.05,
+//	})
+package bfstest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bsm/bfs"
+)
+
+// Op identifies an operation type.
+type Op string
+
+// Operation types.
+const (
+	OpGlob      Op = "glob"
+	OpHead      Op = "head"
+	OpOpen      Op = "open"
+	OpCreate    Op = "create"
+	OpRemove    Op = "remove"
+	OpCopy      Op = "copy"
+	OpRemoveAll Op = "removeall"
+	OpRead      Op = "read"    // a single Read call on a Reader
+	OpWrite     Op = "write"   // a single Write call on a Writer
+	OpCommit    Op = "commit"  // a Writer Commit
+	OpDiscard   Op = "discard" // a Writer Discard
+	OpNext      Op = "next"    // a single Next call on an Iterator
+)
+
+// Supports is the capability matrix of a bucket under test.
+type Supports struct {
+	// ContentType indicates that content types are stored and returned.
+	ContentType bool
+	// Metadata indicates that metadata is stored and returned.
+	Metadata bool
+	// UnicodeKeys indicates support for object names which contain unicode
+	// and special characters, such as spaces, '+', '%' or '#'.
+	UnicodeKeys bool
+	// ConcurrentWrites indicates that concurrent writers to the same key are
+	// safe, i.e. one of the commits wins and objects are never corrupted.
+	ConcurrentWrites bool
+	// ReadCancellation indicates that reads fail once the context passed
+	// to Open is cancelled.
+	ReadCancellation bool
+}
+
+// Common runs the common conformance tests against a bucket. The bucket
+// must be empty.
+func Common(t *testing.T, bucket bfs.Bucket, supports Supports) {
+	ctx := t.Context()
+
+	t.Run("writes", func(t *testing.T) {
+		w, err := bucket.Create(ctx, "blank.txt", nil)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		defer w.Discard()
+
+		assertNoError(t, w.Commit())
+
+		if exp, got := []string{"blank.txt"}, glob(t, bucket, "*"); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		assertError(t, w.Discard())
+		assertNoError(t, bfs.RemoveAll(ctx, bucket, "**"))
+	})
+
+	t.Run("aborts writes on discard", func(t *testing.T) {
+		w, err := bucket.Create(ctx, "blank.txt", nil)
+		assertNoError(t, err)
+		defer w.Discard()
+
+		assertNumEntries(t, bucket, "*", 0)
+
+		assertNoError(t, w.Discard())
+		assertError(t, w.Commit())
+		assertNumEntries(t, bucket, "*", 0)
+	})
+
+	t.Run("aborts writes if context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		w, err := bucket.Create(ctx, "blank.txt", nil)
+		assertNoError(t, err)
+		defer w.Discard()
+
+		assertNumEntries(t, bucket, "*", 0)
+		cancel()
+
+		if exp, got := context.Canceled, w.Commit(); !errors.Is(got, exp) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		assertNumEntries(t, bucket, "*", 0)
+		assertError(t, w.Discard())
+	})
+
+	t.Run("globs", func(t *testing.T) {
+		writeTestData(t, bucket, "path/a/first.txt")
+		writeTestData(t, bucket, "path/b/second.txt")
+		writeTestData(t, bucket, "path/a/third.json")
+
+		assertNumEntries(t, bucket, "*", 0)
+		assertNumEntries(t, bucket, "", 0)
+		assertNumEntries(t, bucket, "path/*", 0)
+		assertNumEntries(t, bucket, "path/*/*", 3)
+		assertNumEntries(t, bucket, "*/*/*", 3)
+		assertNumEntries(t, bucket, "*/a/*", 2)
+		assertNumEntries(t, bucket, "*/b/*", 1)
+		assertNumEntries(t, bucket, "path/*/*.txt", 2)
+		assertNumEntries(t, bucket, "path/*/[ft]*", 2)
+		assertNumEntries(t, bucket, "path/*/[ft]*.json", 1)
+		assertNumEntries(t, bucket, "**", 3)
+
+		assertNoError(t, bfs.RemoveAll(ctx, bucket, "**"))
+	})
+
+	t.Run("heads", func(t *testing.T) {
+		// heading a missing file should fail
+		_, err := bucket.Head(ctx, "path/to/missing")
+		assertNotFound(t, err)
+
+		writeTestData(t, bucket, "path/to/first.txt")
+		info, err := bucket.Head(ctx, "path/to/first.txt")
+		assertNoError(t, err)
+
+		// heading a "dir" should fail
+		_, err = bucket.Head(ctx, "path/to")
+		assertNotFound(t, err)
+
+		if exp, got := "path/to/first.txt", info.Name; exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := int64(8), info.Size; exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := time.Now(), info.ModTime; exp.Sub(got) > time.Minute {
+			t.Errorf("Expected %v (±1m), got %v", exp, got)
+		}
+
+		if supports.Metadata {
+			meta := bfs.Metadata{"Cust0m-Key": "VaLu3"}
+			if exp, got := meta, info.Metadata; !reflect.DeepEqual(exp, got) {
+				t.Errorf("Expected %v, got %v", exp, got)
+			}
+		}
+
+		if supports.ContentType {
+			if exp, got := "text/plain", info.ContentType; exp != got {
+				t.Errorf("Expected %v, got %v", exp, got)
+			}
+		}
+
+		assertNoError(t, bfs.RemoveAll(ctx, bucket, "**"))
+	})
+
+	t.Run("reads", func(t *testing.T) {
+		// opening a missing file should fail
+		_, err := bucket.Open(ctx, "path/to/missing")
+		assertNotFound(t, err)
+
+		writeTestData(t, bucket, "path/to/first.txt")
+		obj, err := bucket.Open(ctx, "path/to/first.txt")
+		assertNoError(t, err)
+
+		// opening a "dir" should fail
+		_, err = bucket.Open(ctx, "path/to")
+		assertNotFound(t, err)
+
+		data := make([]byte, 100)
+		sz, err := obj.Read(data)
+		assertNoError(t, err)
+
+		if exp := 8; exp != sz {
+			t.Errorf("Expected %v, got %v", exp, sz)
+		}
+		if exp, got := "TESTDATA", string(data[:sz]); exp != got {
+			t.Errorf("Expected %v, got %v", exp, sz)
+		}
+
+		assertNoError(t, obj.Close())
+		assertNoError(t, bfs.RemoveAll(ctx, bucket, "**"))
+	})
+
+	t.Run("removes", func(t *testing.T) {
+		writeTestData(t, bucket, "path/to/first.txt")
+		assertNumEntries(t, bucket, "**", 1)
+
+		assertNoError(t, bucket.Remove(ctx, "path/to"))
+		assertNumEntries(t, bucket, "**", 1)
+
+		assertNoError(t, bucket.Remove(ctx, "path/to/first.txt"))
+		assertNumEntries(t, bucket, "**", 0)
+
+		assertNoError(t, bucket.Remove(ctx, "missing.txt"))
+	})
+
+	t.Run("copies", func(t *testing.T) {
+		copier, ok := bucket.(interface {
+			Copy(context.Context, string, string) error
+		})
+		if !ok {
+			t.Skip("Copy is not natively supported")
+		}
+
+		writeTestData(t, bucket, "path/to/src.txt")
+		assertNumEntries(t, bucket, "**", 1)
+		if err := copier.Copy(ctx, "path/to/src.txt", "path/to/dst.txt"); errors.Is(err, errors.ErrUnsupported) {
+			assertNoError(t, bfs.RemoveAll(ctx, bucket, "**"))
+			t.Skip("Copy is not natively supported")
+		} else {
+			assertNoError(t, err)
+		}
+		assertNumEntries(t, bucket, "**", 2)
+
+		info, err := bucket.Head(ctx, "path/to/dst.txt")
+		assertNoError(t, err)
+
+		if exp, got := "path/to/dst.txt", info.Name; exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := int64(8), info.Size; exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := time.Now(), info.ModTime; exp.Sub(got) > time.Minute {
+			t.Errorf("Expected %v (±1m), got %v", exp, got)
+		}
+		if supports.Metadata {
+			if exp, got := (bfs.Metadata{"Cust0m-Key": "VaLu3"}), info.Metadata; !reflect.DeepEqual(exp, got) {
+				t.Errorf("Expected %v, got %v", exp, got)
+			}
+		}
+		if exp, got := "TESTDATA", string(readAll(t, bucket, "path/to/dst.txt")); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		assertNoError(t, bfs.RemoveAll(ctx, bucket, "**"))
+	})
+
+	t.Run("copies via helper", func(t *testing.T) {
+		writeTestData(t, bucket, "path/to/src.txt")
+		assertNoError(t, bfs.CopyObject(ctx, bucket, "path/to/src.txt", "path/to/dst.txt", nil))
+
+		if exp, got := []string{"path/to/dst.txt", "path/to/src.txt"}, glob(t, bucket, "**"); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := "TESTDATA", string(readAll(t, bucket, "path/to/dst.txt")); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		assertNoError(t, bfs.RemoveAll(ctx, bucket, "**"))
+	})
+
+	t.Run("removes all", func(t *testing.T) {
+		remover, ok := bucket.(interface {
+			RemoveAll(context.Context, string) error
+		})
+		if !ok {
+			t.Skip("RemoveAll is not natively supported")
+		}
+
+		writeTestData(t, bucket, "a/b.txt")
+		writeTestData(t, bucket, "a/b/c.txt")
+		writeTestData(t, bucket, "d.txt")
+		writeTestData(t, bucket, "e/f.txt")
+		assertNumEntries(t, bucket, "**", 4)
+
+		if err := remover.RemoveAll(ctx, "a"); errors.Is(err, errors.ErrUnsupported) {
+			assertNoError(t, bfs.RemoveAll(ctx, bucket, "**"))
+			t.Skip("RemoveAll is not natively supported")
+		} else {
+			assertNoError(t, err)
+		}
+		assertNumEntries(t, bucket, "**", 4)
+
+		assertNoError(t, remover.RemoveAll(ctx, "a/**"))
+		if exp, got := []string{"d.txt", "e/f.txt"}, glob(t, bucket, "**"); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %#v, got %#v", exp, got)
+		}
+
+		assertNoError(t, remover.RemoveAll(ctx, "**"))
+		assertNumEntries(t, bucket, "**", 0)
+	})
+
+	t.Run("removes all via helper", func(t *testing.T) {
+		writeTestData(t, bucket, "a/b.txt")
+		writeTestData(t, bucket, "a/b/c.txt")
+		writeTestData(t, bucket, "d.txt")
+		assertNumEntries(t, bucket, "**", 3)
+
+		assertNoError(t, bfs.RemoveAll(ctx, bucket, "a/**"))
+		if exp, got := []string{"d.txt"}, glob(t, bucket, "**"); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %#v, got %#v", exp, got)
+		}
+
+		assertNoError(t, bfs.RemoveAll(ctx, bucket, "**"))
+		assertNumEntries(t, bucket, "**", 0)
+	})
+
+	t.Run("round-trips content type and metadata", func(t *testing.T) {
+		if !supports.ContentType && !supports.Metadata {
+			t.Skip("ContentType and Metadata are not supported")
+		}
+
+		meta := bfs.Metadata{"Key-One": "value", "Key-Two": "with spaces"}
+		assertNoError(t, bfs.WriteObject(ctx, bucket, "path/to/doc.json", []byte("{}"), &bfs.WriteOptions{
+			ContentType: "application/json",
+			Metadata:    meta,
+		}))
+
+		info, err := bucket.Head(ctx, "path/to/doc.json")
+		assertNoError(t, err)
+
+		if supports.Metadata {
+			if exp, got := meta, info.Metadata; !reflect.DeepEqual(exp, got) {
+				t.Errorf("Expected %v, got %v", exp, got)
+			}
+		}
+		if supports.ContentType {
+			if exp, got := "application/json", info.ContentType; exp != got {
+				t.Errorf("Expected %v, got %v", exp, got)
+			}
+		}
+
+		// objects without options have no metadata
+		assertNoError(t, bfs.WriteObject(ctx, bucket, "path/to/plain.json", []byte("[]"), nil))
+
+		info, err = bucket.Head(ctx, "path/to/plain.json")
+		assertNoError(t, err)
+
+		if supports.Metadata && len(info.Metadata) != 0 {
+			t.Errorf("Expected no metadata, got %v", info.Metadata)
+		}
+
+		assertNoError(t, bfs.RemoveAll(ctx, bucket, "**"))
+	})
+
+	t.Run("handles unicode and special keys", func(t *testing.T) {
+		if !supports.UnicodeKeys {
+			t.Skip("Unicode keys are not supported")
+		}
+
+		names := []string{
+			"special/#hash.txt",
+			"special/100% done.txt",
+			"special/a+b=c&d.txt",
+			"special/it's.txt",
+			"unicode/héllo wörld.txt",
+			"unicode/日本語/ファイル.txt",
+		}
+		for _, name := range names {
+			assertNoError(t, bfs.WriteObject(ctx, bucket, name, []byte(name), nil))
+		}
+
+		if exp, got := names, glob(t, bucket, "**"); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := names[4:5], glob(t, bucket, "unicode/*"); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		for _, name := range names {
+			info, err := bucket.Head(ctx, name)
+			assertNoError(t, err)
+
+			if exp, got := name, info.Name; exp != got {
+				t.Errorf("Expected %v, got %v", exp, got)
+			}
+			if exp, got := name, string(readAll(t, bucket, name)); exp != got {
+				t.Errorf("Expected %v, got %v", exp, got)
+			}
+			assertNoError(t, bucket.Remove(ctx, name))
+		}
+		assertNumEntries(t, bucket, "**", 0)
+	})
+
+	t.Run("handles concurrent writers", func(t *testing.T) {
+		if !supports.ConcurrentWrites {
+			t.Skip("Concurrent writes are not supported")
+		}
+
+		const numWriters, size = 8, 64 * 1024
+
+		var wg sync.WaitGroup
+		errs := make([]error, numWriters)
+		for i := range numWriters {
+			wg.Go(func() {
+				data := bytes.Repeat([]byte{byte('a' + i)}, size)
+				errs[i] = bfs.WriteObject(ctx, bucket, "path/to/shared.txt", data, nil)
+			})
+		}
+		wg.Wait()
+		assertNoError(t, errors.Join(errs...))
+
+		data := readAll(t, bucket, "path/to/shared.txt")
+		if exp, got := size, len(data); exp != got {
+			t.Fatalf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := bytes.Repeat(data[:1], size), data; !bytes.Equal(exp, got) {
+			t.Errorf("Expected data of a single writer, got a mix")
+		}
+
+		assertNoError(t, bfs.RemoveAll(ctx, bucket, "**"))
+	})
+
+	t.Run("cancels reads", func(t *testing.T) {
+		if !supports.ReadCancellation {
+			t.Skip("Read cancellation is not supported")
+		}
+
+		assertNoError(t, bfs.WriteObject(ctx, bucket, "path/to/large.bin", make([]byte, 4<<20), nil))
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		r, err := bucket.Open(ctx, "path/to/large.bin")
+		assertNoError(t, err)
+		defer r.Close()
+
+		_, err = io.ReadFull(r, make([]byte, 1))
+		assertNoError(t, err)
+
+		cancel()
+		if _, err := io.Copy(io.Discard, r); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected %v, got %v", context.Canceled, err)
+		}
+		assertNoError(t, r.Close())
+		assertNoError(t, bfs.RemoveAll(t.Context(), bucket, "**"))
+	})
+}
+
+// Slow runs slow conformance tests, with many and with large objects, against
+// a bucket. The bucket must be empty.
+func Slow(t *testing.T, bucket bfs.Bucket, _ Supports) {
+	const numFiles = 2121
+	ctx := t.Context()
+
+	// generate seeds
+	rnd := rand.New(rand.NewSource(33))
+	char := func() rune { return rune(97 + rnd.Intn(26)) }
+
+	for i := 0; i < numFiles; i++ {
+		name := fmt.Sprintf("%c/%c%c%c%c.txt", char(), char(), char(), char(), char())
+		w, err := bucket.Create(ctx, name, nil)
+		assertNoError(t, err)
+		assertNoError(t, w.Commit())
+	}
+
+	t.Run("globs many files", func(t *testing.T) {
+		if exp, got := numFiles, len(glob(t, bucket, "*/*")); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := numFiles, len(glob(t, bucket, "**")); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	assertNoError(t, bfs.RemoveAll(ctx, bucket, "**"))
+
+	t.Run("handles large objects", func(t *testing.T) {
+		data := make([]byte, 5<<20+33)
+		_, _ = rnd.Read(data)
+		assertNoError(t, bfs.WriteObject(ctx, bucket, "path/to/large.bin", data, nil))
+
+		info, err := bucket.Head(ctx, "path/to/large.bin")
+		assertNoError(t, err)
+
+		if exp, got := int64(len(data)), info.Size; exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if got := readAll(t, bucket, "path/to/large.bin"); !bytes.Equal(data, got) {
+			t.Errorf("Expected %d bytes to match, got %d bytes", len(data), len(got))
+		}
+
+		assertNoError(t, bfs.RemoveAll(ctx, bucket, "**"))
+	})
+}
+
+// ----------------------------------------------------------------------------
+
+func assertNumEntries(t *testing.T, bucket bfs.Bucket, pat string, exp int) {
+	t.Helper()
+
+	if got := glob(t, bucket, pat); len(got) != exp {
+		t.Errorf("Expected %d entries, got %d - %v", exp, len(got), got)
+	}
+}
+
+func assertNoError(t *testing.T, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+}
+
+func assertError(t *testing.T, err error) {
+	t.Helper()
+
+	if err == nil {
+		t.Fatal("Expected error, but got none")
+	}
+}
+
+func assertNotFound(t *testing.T, err error) {
+	t.Helper()
+
+	if err == nil || !errors.Is(err, bfs.ErrNotFound) {
+		t.Fatalf("Expected bfs.ErrNotFound, but got %v", err)
+	}
+}
+
+func collect(iter bfs.Iterator) (entries []string) {
+	for iter.Next() {
+		entries = append(entries, iter.Name())
+	}
+	slices.Sort(entries)
+	return entries
+}
+
+func glob(t *testing.T, bucket bfs.Bucket, pat string) []string {
+	t.Helper()
+
+	iter, err := bucket.Glob(t.Context(), pat)
+	assertNoError(t, err)
+	defer iter.Close()
+
+	return collect(iter)
+}
+
+func readAll(t *testing.T, bucket bfs.Bucket, name string) []byte {
+	t.Helper()
+
+	r, err := bucket.Open(t.Context(), name)
+	assertNoError(t, err)
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	assertNoError(t, err)
+	return data
+}
+
+func writeTestData(t *testing.T, bucket bfs.Bucket, name string) {
+	t.Helper()
+
+	assertNoError(t, bfs.WriteObject(t.Context(), bucket, name, []byte("TESTDATA"), &bfs.WriteOptions{
+		Metadata:    bfs.Metadata{"CuSt0m_key": "VaLu3"},
+		ContentType: "text/plain",
+	}))
+}
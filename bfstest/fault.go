@@ -0,0 +1,257 @@
+package bfstest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/bsm/bfs"
+)
+
+// ErrInjected is the default error returned by injected faults.
+var ErrInjected = errors.New("bfstest: injected fault")
+
+// Faults configure fault injection. All rates are probabilities
+// between 0 and 1.
+type Faults struct {
+	// Seed seeds the random number generator. Buckets with the same seed
+	// inject the same faults, given the same sequence of operations.
+	Seed uint64
+	// Err is returned by failing operations. Default: ErrInjected.
+	Err error
+	// ErrorRates configure the error rates per operation type. Commit
+	// errors are injected before any data is written.
+	ErrorRates map[Op]float64
+	// Latency configures the latency to inject per operation type.
+	Latency map[Op]time.Duration
+	// Jitter adds a random duration up to the given value to each
+	// injected latency.
+	Jitter time.Duration
+	// TruncateRate is the probability of a Read call to return only
+	// part of the data, followed by io.ErrUnexpectedEOF.
+	TruncateRate float64
+	// FailAfterCommitRate is the probability of a Commit to report Err
+	// after the data has been written.
+	FailAfterCommitRate float64
+}
+
+// Faulty wraps a bucket and injects faults.
+func Faulty(bucket bfs.Bucket, faults *Faults) bfs.Bucket {
+	return bfs.Wrap(bucket, Middleware(faults))
+}
+
+// Middleware returns a bfs.Middleware which injects faults.
+func Middleware(faults *Faults) bfs.Middleware {
+	f := new(Faults)
+	if faults != nil {
+		*f = *faults
+	}
+	if f.Err == nil {
+		f.Err = ErrInjected
+	}
+	inj := &injector{Faults: f, rnd: rand.New(rand.NewPCG(f.Seed, f.Seed))}
+
+	return bfs.Middleware{
+		Glob: func(ctx context.Context, pattern string, next bfs.GlobFunc) (bfs.Iterator, error) {
+			if err := inj.inject(ctx, OpGlob); err != nil {
+				return nil, err
+			}
+			iter, err := next(ctx, pattern)
+			if err != nil {
+				return nil, err
+			}
+			return &iterator{Iterator: iter, ctx: ctx, inj: inj}, nil
+		},
+		Head: func(ctx context.Context, name string, next bfs.HeadFunc) (*bfs.MetaInfo, error) {
+			if err := inj.inject(ctx, OpHead); err != nil {
+				return nil, err
+			}
+			return next(ctx, name)
+		},
+		Open: func(ctx context.Context, name string, next bfs.OpenFunc) (bfs.Reader, error) {
+			if err := inj.inject(ctx, OpOpen); err != nil {
+				return nil, err
+			}
+			r, err := next(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			return &reader{Reader: r, ctx: ctx, inj: inj}, nil
+		},
+		Create: func(ctx context.Context, name string, opts *bfs.WriteOptions, next bfs.CreateFunc) (bfs.Writer, error) {
+			if err := inj.inject(ctx, OpCreate); err != nil {
+				return nil, err
+			}
+			w, err := next(ctx, name, opts)
+			if err != nil {
+				return nil, err
+			}
+			return &writer{Writer: w, ctx: ctx, inj: inj}, nil
+		},
+		Remove: func(ctx context.Context, name string, next bfs.RemoveFunc) error {
+			if err := inj.inject(ctx, OpRemove); err != nil {
+				return err
+			}
+			return next(ctx, name)
+		},
+		Copy: func(ctx context.Context, src, dst string, next bfs.CopyFunc) error {
+			if err := inj.inject(ctx, OpCopy); err != nil {
+				return err
+			}
+			return next(ctx, src, dst)
+		},
+		RemoveAll: func(ctx context.Context, pattern string, next bfs.RemoveAllFunc) error {
+			if err := inj.inject(ctx, OpRemoveAll); err != nil {
+				return err
+			}
+			return next(ctx, pattern)
+		},
+	}
+}
+
+// --------------------------------------------------------------------
+
+type injector struct {
+	*Faults
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// roll returns true with the given probability.
+func (i *injector) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rnd.Float64() < rate
+}
+
+// intN returns a random number in [0,n).
+func (i *injector) intN(n int) int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rnd.IntN(n)
+}
+
+// delay returns the latency to inject.
+func (i *injector) delay(op Op) time.Duration {
+	d := i.Latency[op]
+	if i.Jitter > 0 {
+		d += time.Duration(i.intN(int(i.Jitter)))
+	}
+	return d
+}
+
+// inject injects latency and errors.
+func (i *injector) inject(ctx context.Context, op Op) error {
+	if d := i.delay(op); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+
+	if i.roll(i.ErrorRates[op]) {
+		return i.Err
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------
+
+type reader struct {
+	bfs.Reader
+
+	ctx context.Context
+	inj *injector
+	err error
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if err := r.inj.inject(r.ctx, OpRead); err != nil {
+		r.err = err
+		return 0, err
+	}
+
+	if len(p) != 0 && r.inj.roll(r.inj.TruncateRate) {
+		r.err = io.ErrUnexpectedEOF
+		p = p[:r.inj.intN(len(p))]
+		if len(p) == 0 {
+			return 0, r.err
+		}
+		n, err := r.Reader.Read(p)
+		if err == nil {
+			err = r.err
+		}
+		return n, err
+	}
+	return r.Reader.Read(p)
+}
+
+type writer struct {
+	bfs.Writer
+
+	ctx context.Context
+	inj *injector
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if err := w.inj.inject(w.ctx, OpWrite); err != nil {
+		return 0, err
+	}
+	return w.Writer.Write(p)
+}
+
+func (w *writer) Commit() error {
+	if err := w.inj.inject(w.ctx, OpCommit); err != nil {
+		_ = w.Writer.Discard()
+		return err
+	}
+
+	if err := w.Writer.Commit(); err != nil {
+		return err
+	}
+	if w.inj.roll(w.inj.FailAfterCommitRate) {
+		return w.inj.Err
+	}
+	return nil
+}
+
+type iterator struct {
+	bfs.Iterator
+
+	ctx context.Context
+	inj *injector
+	err error
+}
+
+func (i *iterator) Next() bool {
+	if i.err != nil {
+		return false
+	}
+	if err := i.inj.inject(i.ctx, OpNext); err != nil {
+		i.err = err
+		return false
+	}
+	return i.Iterator.Next()
+}
+
+func (i *iterator) Error() error {
+	if i.err != nil {
+		return i.err
+	}
+	return i.Iterator.Error()
+}
@@ -0,0 +1,158 @@
+package bfstest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bsm/bfs"
+	"github.com/bsm/bfs/bfstest"
+)
+
+func TestFaulty(t *testing.T) {
+	t.Run("lint", func(t *testing.T) {
+		bucket := bfstest.Faulty(bfs.NewInMem(), nil)
+		bfstest.Common(t, bucket, bfstest.Supports{Metadata: true})
+	})
+
+	t.Run("injects errors", func(t *testing.T) {
+		ctx := t.Context()
+		errCustom := errors.New("custom")
+		bucket := bfstest.Faulty(bfs.NewInMem(), &bfstest.Faults{
+			Err:        errCustom,
+			ErrorRates: map[bfstest.Op]float64{bfstest.OpHead: 1, bfstest.OpWrite: 1},
+		})
+
+		if _, err := bucket.Head(ctx, "a.txt"); err != errCustom {
+			t.Errorf("Expected %v, got %v", errCustom, err)
+		}
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), nil); err != errCustom {
+			t.Errorf("Expected %v, got %v", errCustom, err)
+		}
+		if err := bucket.Remove(ctx, "a.txt"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+	})
+
+	t.Run("is deterministic", func(t *testing.T) {
+		ctx := t.Context()
+		sample := func(seed uint64) []bool {
+			bucket := bfstest.Faulty(bfs.NewInMem(), &bfstest.Faults{
+				Seed:       seed,
+				ErrorRates: map[bfstest.Op]float64{bfstest.OpHead: 0.5},
+			})
+
+			var res []bool
+			for range 64 {
+				_, err := bucket.Head(ctx, "a.txt")
+				res = append(res, err == bfstest.ErrInjected)
+			}
+			return res
+		}
+
+		if a, b := sample(42), sample(42); !reflect.DeepEqual(a, b) {
+			t.Errorf("Expected %v, got %v", a, b)
+		}
+		if a, b := sample(42), sample(43); reflect.DeepEqual(a, b) {
+			t.Errorf("Expected samples to differ")
+		}
+	})
+
+	t.Run("truncates reads", func(t *testing.T) {
+		ctx := t.Context()
+		base := bfs.NewInMem()
+		if err := bfs.WriteObject(ctx, base, "a.txt", make([]byte, 1000), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		bucket := bfstest.Faulty(base, &bfstest.Faults{TruncateRate: 1})
+		r, err := bucket.Open(ctx, "a.txt")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		defer r.Close()
+
+		data, err := io.ReadAll(r)
+		if err != io.ErrUnexpectedEOF {
+			t.Errorf("Expected %v, got %v", io.ErrUnexpectedEOF, err)
+		}
+		if len(data) >= 1000 {
+			t.Errorf("Expected truncated data, got %d bytes", len(data))
+		}
+	})
+
+	t.Run("fails iterators mid-listing", func(t *testing.T) {
+		ctx := t.Context()
+		base := bfs.NewInMem()
+		for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+			if err := bfs.WriteObject(ctx, base, name, []byte("data"), nil); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+		}
+
+		bucket := bfstest.Faulty(base, &bfstest.Faults{
+			Seed:       1,
+			ErrorRates: map[bfstest.Op]float64{bfstest.OpNext: 0.5},
+		})
+		iter, err := bucket.Glob(ctx, "*")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		defer iter.Close()
+
+		var n int
+		for iter.Next() {
+			n++
+		}
+		if err := iter.Error(); err != bfstest.ErrInjected {
+			t.Errorf("Expected %v, got %v", bfstest.ErrInjected, err)
+		}
+		if n >= 8 {
+			t.Errorf("Expected listing to fail early, got %d entries", n)
+		}
+	})
+
+	t.Run("fails after commit", func(t *testing.T) {
+		ctx := t.Context()
+		base := bfs.NewInMem()
+		bucket := bfstest.Faulty(base, &bfstest.Faults{FailAfterCommitRate: 1})
+
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), nil); err != bfstest.ErrInjected {
+			t.Errorf("Expected %v, got %v", bfstest.ErrInjected, err)
+		}
+		if exp, got := map[string]int64{"a.txt": 4}, base.ObjectSizes(); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("fails commit", func(t *testing.T) {
+		ctx := t.Context()
+		base := bfs.NewInMem()
+		bucket := bfstest.Faulty(base, &bfstest.Faults{
+			ErrorRates: map[bfstest.Op]float64{bfstest.OpCommit: 1},
+		})
+
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), nil); err != bfstest.ErrInjected {
+			t.Errorf("Expected %v, got %v", bfstest.ErrInjected, err)
+		}
+		if exp, got := map[string]int64{}, base.ObjectSizes(); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("injects latency", func(t *testing.T) {
+		bucket := bfstest.Faulty(bfs.NewInMem(), &bfstest.Faults{
+			Latency: map[bfstest.Op]time.Duration{bfstest.OpHead: time.Minute},
+		})
+
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+		defer cancel()
+
+		if _, err := bucket.Head(ctx, "a.txt"); err != context.DeadlineExceeded {
+			t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+		}
+	})
+}
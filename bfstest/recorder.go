@@ -0,0 +1,293 @@
+package bfstest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bsm/bfs"
+)
+
+// Call is a recorded bucket operation.
+type Call struct {
+	// Op is the operation type, one of OpGlob, OpHead, OpOpen, OpCreate,
+	// OpCommit, OpDiscard, OpRemove, OpCopy or OpRemoveAll.
+	Op Op
+	// Name is the object name, the pattern of Glob and RemoveAll calls or
+	// the source of Copy calls.
+	Name string
+	// Dst is the destination of Copy calls.
+	Dst string
+	// Size is the number of bytes written, for Commit and Discard calls.
+	Size int64
+	// Options are the options passed to Create, for Create, Commit and
+	// Discard calls.
+	Options *bfs.WriteOptions
+	// Err is the error returned by the operation.
+	Err error
+}
+
+// String returns a short description.
+func (c Call) String() string {
+	var sb strings.Builder
+	sb.WriteString(string(c.Op))
+	sb.WriteString(" ")
+	sb.WriteString(c.Name)
+	if c.Dst != "" {
+		sb.WriteString(" -> ")
+		sb.WriteString(c.Dst)
+	}
+	if c.Op == OpCommit || c.Op == OpDiscard {
+		fmt.Fprintf(&sb, " (%d bytes)", c.Size)
+	}
+	if c.Err != nil {
+		fmt.Fprintf(&sb, ": %v", c.Err)
+	}
+	return sb.String()
+}
+
+// Recorder is a bucket which records all operations on a parent bucket.
+type Recorder struct {
+	bfs.Bucket
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// NewRecorder wraps a bucket and records all operations.
+func NewRecorder(bucket bfs.Bucket) *Recorder {
+	r := new(Recorder)
+	r.Bucket = bfs.Wrap(bucket, bfs.Middleware{
+		Glob: func(ctx context.Context, pattern string, next bfs.GlobFunc) (bfs.Iterator, error) {
+			iter, err := next(ctx, pattern)
+			r.record(Call{Op: OpGlob, Name: pattern, Err: err})
+			return iter, err
+		},
+		Head: func(ctx context.Context, name string, next bfs.HeadFunc) (*bfs.MetaInfo, error) {
+			info, err := next(ctx, name)
+			r.record(Call{Op: OpHead, Name: name, Err: err})
+			return info, err
+		},
+		Open: func(ctx context.Context, name string, next bfs.OpenFunc) (bfs.Reader, error) {
+			rd, err := next(ctx, name)
+			r.record(Call{Op: OpOpen, Name: name, Err: err})
+			return rd, err
+		},
+		Create: func(ctx context.Context, name string, opts *bfs.WriteOptions, next bfs.CreateFunc) (bfs.Writer, error) {
+			w, err := next(ctx, name, opts)
+			r.record(Call{Op: OpCreate, Name: name, Options: opts, Err: err})
+			if err != nil {
+				return nil, err
+			}
+			return &recordingWriter{Writer: w, rec: r, name: name, opts: opts}, nil
+		},
+		Remove: func(ctx context.Context, name string, next bfs.RemoveFunc) error {
+			err := next(ctx, name)
+			r.record(Call{Op: OpRemove, Name: name, Err: err})
+			return err
+		},
+		Copy: func(ctx context.Context, src, dst string, next bfs.CopyFunc) error {
+			err := next(ctx, src, dst)
+			r.record(Call{Op: OpCopy, Name: src, Dst: dst, Err: err})
+			return err
+		},
+		RemoveAll: func(ctx context.Context, pattern string, next bfs.RemoveAllFunc) error {
+			err := next(ctx, pattern)
+			r.record(Call{Op: OpRemoveAll, Name: pattern, Err: err})
+			return err
+		},
+	})
+	return r
+}
+
+// Copy implements bfs.Bucket extension. It records the call and returns
+// errors.ErrUnsupported if the parent bucket does not support native copies,
+// which makes bfs.CopyObject fall back on Open and Create.
+func (r *Recorder) Copy(ctx context.Context, src, dst string) error {
+	return r.Bucket.(interface {
+		Copy(context.Context, string, string) error
+	}).Copy(ctx, src, dst)
+}
+
+// RemoveAll implements bfs.Bucket extension. It records the call and returns
+// errors.ErrUnsupported if the parent bucket does not support native bulk
+// removals, which makes bfs.RemoveAll fall back on Glob and Remove.
+func (r *Recorder) RemoveAll(ctx context.Context, pattern string) error {
+	return r.Bucket.(interface {
+		RemoveAll(context.Context, string) error
+	}).RemoveAll(ctx, pattern)
+}
+
+// ListVersions implements bfs.Versioned. Version calls are forwarded to the
+// parent bucket, but not recorded. They return errors.ErrUnsupported if the
+// parent bucket does not support versioning.
+func (r *Recorder) ListVersions(ctx context.Context, name string) ([]*bfs.VersionInfo, error) {
+	return r.Bucket.(bfs.Versioned).ListVersions(ctx, name)
+}
+
+// HeadVersion implements bfs.Versioned.
+func (r *Recorder) HeadVersion(ctx context.Context, name, versionID string) (*bfs.MetaInfo, error) {
+	return r.Bucket.(bfs.Versioned).HeadVersion(ctx, name, versionID)
+}
+
+// OpenVersion implements bfs.Versioned.
+func (r *Recorder) OpenVersion(ctx context.Context, name, versionID string) (bfs.Reader, error) {
+	return r.Bucket.(bfs.Versioned).OpenVersion(ctx, name, versionID)
+}
+
+// RemoveVersion implements bfs.Versioned.
+func (r *Recorder) RemoveVersion(ctx context.Context, name, versionID string) error {
+	return r.Bucket.(bfs.Versioned).RemoveVersion(ctx, name, versionID)
+}
+
+// RestoreVersion implements bfs.Versioned extension.
+func (r *Recorder) RestoreVersion(ctx context.Context, name, versionID string) error {
+	return r.Bucket.(interface {
+		RestoreVersion(context.Context, string, string) error
+	}).RestoreVersion(ctx, name, versionID)
+}
+
+// Notify implements bfs.Bucket extension. Calls are forwarded to the parent
+// bucket, but not recorded. It returns errors.ErrUnsupported if the parent
+// bucket does not support native notifications.
+func (r *Recorder) Notify(ctx context.Context, pattern string) (<-chan struct{}, error) {
+	return r.Bucket.(interface {
+		Notify(context.Context, string) (<-chan struct{}, error)
+	}).Notify(ctx, pattern)
+}
+
+// Calls returns the recorded calls, optionally filtered by operation types.
+func (r *Recorder) Calls(ops ...Op) []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(ops) == 0 {
+		return slices.Clone(r.calls)
+	}
+
+	var calls []Call
+	for _, c := range r.calls {
+		if slices.Contains(ops, c.Op) {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Reset clears all recorded calls.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls = r.calls[:0]
+}
+
+// Committed returns the names of all successfully committed objects,
+// in the order of their commits.
+func (r *Recorder) Committed() []string {
+	var names []string
+	for _, c := range r.Calls(OpCommit) {
+		if c.Err == nil {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+// AssertCommitted asserts that exactly the given objects were successfully
+// committed, in any order.
+func (r *Recorder) AssertCommitted(t testing.TB, names ...string) {
+	t.Helper()
+
+	exp := slices.Sorted(slices.Values(names))
+	got := slices.Sorted(slices.Values(r.Committed()))
+	if !slices.Equal(exp, got) {
+		t.Errorf("Expected committed %v, got %v", exp, got)
+	}
+}
+
+// AssertCalled asserts that an operation was called on name at least once.
+func (r *Recorder) AssertCalled(t testing.TB, op Op, name string) {
+	t.Helper()
+
+	if !slices.ContainsFunc(r.Calls(op), func(c Call) bool { return c.Name == name }) {
+		t.Errorf("Expected %s %s to be called, got %v", op, name, r.Calls())
+	}
+}
+
+// AssertNotCalled asserts that none of the given operation types were called.
+func (r *Recorder) AssertNotCalled(t testing.TB, ops ...Op) {
+	t.Helper()
+
+	if calls := r.Calls(ops...); len(calls) != 0 {
+		t.Errorf("Expected no %v calls, got %v", ops, calls)
+	}
+}
+
+// AssertOrder asserts that the given calls occurred in the given order,
+// possibly interleaved with other calls. Calls are matched by Op and Name.
+func (r *Recorder) AssertOrder(t testing.TB, calls ...Call) {
+	t.Helper()
+
+	pos := 0
+	for _, c := range r.Calls() {
+		if pos < len(calls) && c.Op == calls[pos].Op && c.Name == calls[pos].Name {
+			pos++
+		}
+	}
+	if pos < len(calls) {
+		t.Errorf("Expected calls in order %v, got %v", calls, r.Calls())
+	}
+}
+
+func (r *Recorder) record(c Call) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls = append(r.calls, c)
+}
+
+// --------------------------------------------------------------------
+
+// recordingWriter records the first Commit or Discard only, e.g. a deferred
+// Discard after a successful Commit is not recorded.
+type recordingWriter struct {
+	bfs.Writer
+
+	rec    *Recorder
+	name   string
+	opts   *bfs.WriteOptions
+	size   int64
+	closed bool
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *recordingWriter) Commit() error {
+	if w.closed {
+		return w.Writer.Commit()
+	}
+	w.closed = true
+
+	err := w.Writer.Commit()
+	w.rec.record(Call{Op: OpCommit, Name: w.name, Size: w.size, Options: w.opts, Err: err})
+	return err
+}
+
+func (w *recordingWriter) Discard() error {
+	if w.closed {
+		return w.Writer.Discard()
+	}
+	w.closed = true
+
+	err := w.Writer.Discard()
+	w.rec.record(Call{Op: OpDiscard, Name: w.name, Size: w.size, Options: w.opts, Err: err})
+	return err
+}
@@ -0,0 +1,134 @@
+package bfstest_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bsm/bfs"
+	"github.com/bsm/bfs/bfstest"
+)
+
+func TestRecorder(t *testing.T) {
+	t.Run("lint", func(t *testing.T) {
+		bucket := bfstest.NewRecorder(bfs.NewInMem())
+		bfstest.Common(t, bucket, bfstest.Supports{Metadata: true})
+	})
+
+	t.Run("records calls", func(t *testing.T) {
+		ctx := t.Context()
+		bucket := bfstest.NewRecorder(bfs.NewInMem())
+		opts := &bfs.WriteOptions{ContentType: "text/plain"}
+
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), opts); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		w, err := bucket.Create(ctx, "b.txt", nil)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if _, err := w.Write([]byte("abc")); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := w.Discard(); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if _, err := bucket.Head(ctx, "b.txt"); err != bfs.ErrNotFound {
+			t.Errorf("Expected %v, got %v", bfs.ErrNotFound, err)
+		}
+
+		if exp, got := []bfstest.Call{
+			{Op: bfstest.OpCreate, Name: "a.txt", Options: opts},
+			{Op: bfstest.OpCommit, Name: "a.txt", Size: 4, Options: opts},
+			{Op: bfstest.OpCreate, Name: "b.txt"},
+			{Op: bfstest.OpDiscard, Name: "b.txt", Size: 3},
+			{Op: bfstest.OpHead, Name: "b.txt", Err: bfs.ErrNotFound},
+		}, bucket.Calls(); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := []string{"a.txt"}, bucket.Committed(); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		bucket.AssertCommitted(t, "a.txt")
+		bucket.AssertCalled(t, bfstest.OpDiscard, "b.txt")
+		bucket.AssertNotCalled(t, bfstest.OpRemove, bfstest.OpRemoveAll)
+		bucket.AssertOrder(t,
+			bfstest.Call{Op: bfstest.OpCommit, Name: "a.txt"},
+			bfstest.Call{Op: bfstest.OpDiscard, Name: "b.txt"},
+		)
+
+		bucket.Reset()
+		if exp, got := 0, len(bucket.Calls()); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("records copies and bulk removals", func(t *testing.T) {
+		ctx := t.Context()
+		bucket := bfstest.NewRecorder(bfs.NewInMem())
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		bucket.Reset()
+		if err := bfs.CopyObject(ctx, bucket, "a.txt", "b.txt", nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bfs.RemoveAll(ctx, bucket, "*"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		// InMem does not support native copies
+		if exp, got := []bfstest.Call{
+			{Op: bfstest.OpCopy, Name: "a.txt", Dst: "b.txt", Err: errors.ErrUnsupported},
+			{Op: bfstest.OpOpen, Name: "a.txt"},
+			{Op: bfstest.OpCreate, Name: "b.txt"},
+			{Op: bfstest.OpCommit, Name: "b.txt", Size: 4},
+			{Op: bfstest.OpRemoveAll, Name: "*"},
+		}, bucket.Calls(); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		bucket.AssertNotCalled(t, bfstest.OpRemove, bfstest.OpGlob)
+	})
+
+	t.Run("fails assertions", func(t *testing.T) {
+		ctx := t.Context()
+		bucket := bfstest.NewRecorder(bfs.NewInMem())
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bucket.Remove(ctx, "a.txt"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		for _, fn := range []func(testing.TB){
+			func(tb testing.TB) { bucket.AssertCommitted(tb, "a.txt", "b.txt") },
+			func(tb testing.TB) { bucket.AssertCalled(tb, bfstest.OpRemove, "b.txt") },
+			func(tb testing.TB) { bucket.AssertNotCalled(tb, bfstest.OpRemove) },
+			func(tb testing.TB) {
+				bucket.AssertOrder(tb,
+					bfstest.Call{Op: bfstest.OpRemove, Name: "a.txt"},
+					bfstest.Call{Op: bfstest.OpCommit, Name: "a.txt"},
+				)
+			},
+		} {
+			mock := &mockTB{TB: t}
+			fn(mock)
+			if !mock.failed {
+				t.Errorf("Expected assertion to fail")
+			}
+		}
+	})
+}
+
+type mockTB struct {
+	testing.TB
+
+	failed bool
+}
+
+func (*mockTB) Helper() {}
+
+func (m *mockTB) Errorf(string, ...any) { m.failed = true }
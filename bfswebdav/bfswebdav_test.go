@@ -0,0 +1,70 @@
+package bfswebdav_test
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/bsm/bfs"
+	"github.com/bsm/bfs/bfstest"
+	"github.com/bsm/bfs/bfswebdav"
+	"golang.org/x/net/webdav"
+)
+
+func Test(t *testing.T) {
+	server := httptest.NewServer(&webdav.Handler{
+		FileSystem: webdav.NewMemFS(),
+		LockSystem: webdav.NewMemLS(),
+	})
+	defer server.Close()
+
+	bucket, err := bfswebdav.New(server.URL, &bfswebdav.Config{Prefix: "x/y"})
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	defer bucket.Close()
+
+	t.Run("common", func(t *testing.T) {
+		bfstest.Common(t, bucket, bfstest.Supports{Metadata: true})
+	})
+
+	t.Run("slow", func(t *testing.T) {
+		bfstest.Slow(t, bucket, bfstest.Supports{Metadata: true})
+	})
+
+	t.Run("renames", func(t *testing.T) {
+		ctx := t.Context()
+		renamer := bucket.(interface {
+			Rename(context.Context, string, string) error
+		})
+
+		if err := bfs.WriteObject(ctx, bucket, "src.txt", []byte("TESTDATA"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := renamer.Rename(ctx, "src.txt", "path/to/dst.txt"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		if _, err := bucket.Head(ctx, "src.txt"); err != bfs.ErrNotFound {
+			t.Errorf("Expected %v, got %v", bfs.ErrNotFound, err)
+		}
+
+		r, err := bucket.Open(ctx, "path/to/dst.txt")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		defer r.Close()
+
+		if data, err := io.ReadAll(r); err != nil {
+			t.Fatal("Unexpected error", err)
+		} else if exp, got := []byte("TESTDATA"), data; !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %q, got %q", exp, got)
+		}
+
+		if err := bfs.RemoveAll(ctx, bucket, "**"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+	})
+}
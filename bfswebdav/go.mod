@@ -0,0 +1,9 @@
+module github.com/bsm/bfs/bfswebdav
+
+go 1.25
+
+require (
+	github.com/bmatcuk/doublestar/v4 v4.9.1
+	github.com/bsm/bfs v0.13.0
+	golang.org/x/net v0.46.0
+)
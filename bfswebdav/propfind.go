@@ -0,0 +1,140 @@
+package bfswebdav
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bsm/bfs"
+)
+
+const propfindBody = xml.Header + `<D:propfind xmlns:D="DAV:" xmlns:B="` + MetadataNamespace + `"><D:prop>` +
+	`<D:resourcetype/><D:getcontentlength/><D:getlastmodified/><D:getcontenttype/><B:metadata/>` +
+	`</D:prop></D:propfind>`
+
+// resource is a parsed PROPFIND response entry.
+type resource struct {
+	name        string
+	collection  bool
+	size        int64
+	modTime     time.Time
+	contentType string
+	metadata    bfs.Metadata
+}
+
+func (r *resource) metaInfo(name string) *bfs.MetaInfo {
+	return &bfs.MetaInfo{
+		Name:        name,
+		Size:        r.size,
+		ModTime:     r.modTime,
+		ContentType: r.contentType,
+		Metadata:    r.metadata,
+	}
+}
+
+// propfind retrieves properties of a resource, and of its members if
+// depth is "1".
+func (b *bucket) propfind(ctx context.Context, name, depth string) ([]*resource, error) {
+	req, err := b.newRequest(ctx, "PROPFIND", b.resourceURL(name), strings.NewReader(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", depth)
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	resp, err := b.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, &StatusError{Method: req.Method, URL: req.URL.String(), StatusCode: resp.StatusCode}
+	}
+
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, err
+	}
+
+	resources := make([]*resource, 0, len(ms.Responses))
+	for _, r := range ms.Responses {
+		res, err := b.parseResponse(&r)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, res)
+	}
+	return resources, nil
+}
+
+func (b *bucket) parseResponse(r *msResponse) (*resource, error) {
+	href, err := url.Parse(r.Href)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &resource{
+		name:     b.stripPrefix(strings.TrimRight(href.Path, "/")),
+		metadata: bfs.Metadata{},
+	}
+	for _, ps := range r.Propstats {
+		if !strings.Contains(ps.Status, " 200 ") {
+			continue
+		}
+
+		if ps.Prop.ResourceType.Collection != nil {
+			res.collection = true
+		}
+		if s := ps.Prop.ContentLength; s != "" {
+			if res.size, err = strconv.ParseInt(strings.TrimSpace(s), 10, 64); err != nil {
+				return nil, err
+			}
+		}
+		if s := ps.Prop.LastModified; s != "" {
+			if res.modTime, err = http.ParseTime(strings.TrimSpace(s)); err != nil {
+				return nil, err
+			}
+		}
+		if s := ps.Prop.ContentType; s != "" {
+			res.contentType = s
+		}
+		if s := ps.Prop.Metadata; s != "" {
+			meta := make(map[string]string)
+			if err := json.Unmarshal([]byte(s), &meta); err != nil {
+				return nil, err
+			}
+			res.metadata = bfs.NormMetadata(meta)
+		}
+	}
+	return res, nil
+}
+
+type multistatus struct {
+	Responses []msResponse `xml:"DAV: response"`
+}
+
+type msResponse struct {
+	Href      string       `xml:"DAV: href"`
+	Propstats []msPropstat `xml:"DAV: propstat"`
+}
+
+type msPropstat struct {
+	Prop   msProp `xml:"DAV: prop"`
+	Status string `xml:"DAV: status"`
+}
+
+type msProp struct {
+	ResourceType struct {
+		Collection *struct{} `xml:"DAV: collection"`
+	} `xml:"DAV: resourcetype"`
+	ContentLength string `xml:"DAV: getcontentlength"`
+	LastModified  string `xml:"DAV: getlastmodified"`
+	ContentType   string `xml:"DAV: getcontenttype"`
+	Metadata      string `xml:"https://github.com/bsm/bfs metadata"`
+}
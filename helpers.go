@@ -2,9 +2,12 @@ package bfs
 
 import (
 	"context"
+	"errors"
 	"io"
 )
 
+// supportsCopy and supportsRemoveAll are optional extensions. Implementations
+// may return errors.ErrUnsupported to trigger the generic fallbacks.
 type supportsCopy interface {
 	Copy(context.Context, string, string) error
 }
@@ -13,6 +16,13 @@ type supportsRemoveAll interface {
 	RemoveAll(context.Context, string) error
 }
 
+// supportsCreateNew is an optional extension for exclusive creation. Commits
+// of writers returned by CreateNew must fail with ErrExists if the object
+// exists at the time of the commit.
+type supportsCreateNew interface {
+	CreateNew(context.Context, string, *WriteOptions) (Writer, error)
+}
+
 // WriteObject is a quick write helper.
 func WriteObject(ctx context.Context, bucket Bucket, name string, data []byte, opts *WriteOptions) error {
 	w, err := bucket.Create(ctx, name, opts)
@@ -30,7 +40,9 @@ func WriteObject(ctx context.Context, bucket Bucket, name string, data []byte, o
 // CopyObject is a quick helper to copy objects within the same bucket.
 func CopyObject(ctx context.Context, bucket Bucket, src, dst string, dstOpts *WriteOptions) error {
 	if b, ok := bucket.(supportsCopy); ok {
-		return b.Copy(ctx, src, dst)
+		if err := b.Copy(ctx, src, dst); !errors.Is(err, errors.ErrUnsupported) {
+			return err
+		}
 	}
 
 	r, err := bucket.Open(ctx, src)
@@ -54,7 +66,9 @@ func CopyObject(ctx context.Context, bucket Bucket, src, dst string, dstOpts *Wr
 // RemoveAll removes all files matching the pattern.
 func RemoveAll(ctx context.Context, bucket Bucket, pattern string) error {
 	if b, ok := bucket.(supportsRemoveAll); ok {
-		return b.RemoveAll(ctx, pattern)
+		if err := b.RemoveAll(ctx, pattern); !errors.Is(err, errors.ErrUnsupported) {
+			return err
+		}
 	}
 
 	it, err := bucket.Glob(ctx, pattern)
@@ -3,43 +3,143 @@ package bfs
 import (
 	"bytes"
 	"context"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bmatcuk/doublestar/v4"
 )
 
+// ErrQuotaExceeded is returned by InMem on attempts to commit objects beyond
+// the configured capacity limits.
+var ErrQuotaExceeded = errors.New("bfs: quota exceeded")
+
+// InMemConfig contains optional InMem configuration.
+type InMemConfig struct {
+	// Clock returns the current time, which is used to stamp the
+	// modification time of objects unless WriteOptions.ModTime is set.
+	// Default: time.Now
+	Clock func() time.Time
+
+	// MaxBytes limits the total size of all stored objects. Commits which
+	// would exceed the limit fail with ErrQuotaExceeded.
+	// Default: 0 (unlimited)
+	MaxBytes int64
+	// MaxObjects limits the number of stored objects. Commits which would
+	// exceed the limit fail with ErrQuotaExceeded.
+	// Default: 0 (unlimited)
+	MaxObjects int
+
+	// ListingDelay simulates eventual consistency. Writes and removals only
+	// become visible to Glob once this duration (as measured by Clock) has
+	// passed. Head and Open are always consistent.
+	// Default: 0 (consistent)
+	ListingDelay time.Duration
+	// ListingLag simulates eventual consistency. Writes and removals only
+	// become visible to Glob after this number of subsequent bucket
+	// operations. When combined with ListingDelay, both conditions must be met.
+	// Default: 0 (consistent)
+	ListingLag int
+
+	// Versioning enables object versioning, see Versioned. Overwrites and
+	// removals retain previous versions, removals create delete markers.
+	// Default: false
+	Versioning bool
+}
+
+func (c *InMemConfig) norm() *InMemConfig {
+	if c.Clock == nil {
+		c.Clock = time.Now
+	}
+	return c
+}
+
+func (c *InMemConfig) eventual() bool {
+	return c.ListingDelay > 0 || c.ListingLag > 0
+}
+
 // InMem is an in-memory Bucket implementation which can be used for mocking.
+// Glob results are sorted by name.
 type InMem struct {
 	objects map[string]*inMemObject
 	mu      sync.RWMutex
+	config  *InMemConfig
+
+	// listed and pending simulate eventual consistency of listings.
+	listed  map[string]*inMemObject
+	pending []inMemChange
+	ops     atomic.Int64
+
+	// versions contains all versions of objects, oldest first.
+	versions map[string][]*inMemObject
+	seq      int64
 }
 
 // NewInMem returns an initialised Bucket.
 func NewInMem() *InMem {
-	return &InMem{
+	return NewInMemWithConfig(nil)
+}
+
+// NewInMemWithConfig returns an initialised Bucket with custom configuration.
+func NewInMemWithConfig(cfg *InMemConfig) *InMem {
+	config := new(InMemConfig)
+	if cfg != nil {
+		*config = *cfg
+	}
+
+	b := &InMem{
 		objects: make(map[string]*inMemObject),
+		config:  config.norm(),
+	}
+	if b.config.eventual() {
+		b.listed = make(map[string]*inMemObject)
+	}
+	if b.config.Versioning {
+		b.versions = make(map[string][]*inMemObject)
 	}
+	return b
 }
 
 // Glob implements Bucket.
 func (b *InMem) Glob(_ context.Context, pattern string) (Iterator, error) {
+	b.ops.Add(1)
 	b.mu.RLock()
+	if len(b.pending) != 0 {
+		// take the write lock to apply pending changes only
+		b.mu.RUnlock()
+		b.mu.Lock()
+		b.applyPending()
+		b.mu.Unlock()
+		b.mu.RLock()
+	}
 	defer b.mu.RUnlock()
 
+	objects := b.objects
+	if b.listed != nil {
+		objects = b.listed
+	}
+
 	var matches []*inMemObject
-	for key := range b.objects {
+	for key, obj := range objects {
 		if ok, err := doublestar.Match(pattern, key); err != nil {
 			return nil, err
 		} else if ok {
-			matches = append(matches, b.objects[key])
+			matches = append(matches, obj)
 		}
 	}
+	slices.SortFunc(matches, func(a, b *inMemObject) int {
+		return strings.Compare(a.info.Name, b.info.Name)
+	})
 	return &inMemIterator{entries: matches, pos: -1}, nil
 }
 
 // Head implements Bucket.
 func (b *InMem) Head(_ context.Context, name string) (*MetaInfo, error) {
+	b.ops.Add(1)
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
@@ -53,6 +153,7 @@ func (b *InMem) Head(_ context.Context, name string) (*MetaInfo, error) {
 
 // Open implements Bucket.
 func (b *InMem) Open(_ context.Context, name string) (Reader, error) {
+	b.ops.Add(1)
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
@@ -77,17 +178,30 @@ func (b *InMem) Create(ctx context.Context, name string, opts *WriteOptions) (Wr
 	}, nil
 }
 
+// CreateNew implements Bucket extension. The returned writer
+// fails to commit with ErrExists if the object already exists.
+func (b *InMem) CreateNew(ctx context.Context, name string, opts *WriteOptions) (Writer, error) {
+	w, err := b.Create(ctx, name, opts)
+	if err != nil {
+		return nil, err
+	}
+	w.(*inMemWriter).exclusive = true
+	return w, nil
+}
+
 // Remove implements Bucket.
 func (b *InMem) Remove(_ context.Context, name string) error {
+	b.ops.Add(1)
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	delete(b.objects, name)
+	b.drop(name)
 	return nil
 }
 
 // RemoveAll implements Bucket extension.
 func (b *InMem) RemoveAll(_ context.Context, pattern string) error {
+	b.ops.Add(1)
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
@@ -95,7 +209,7 @@ func (b *InMem) RemoveAll(_ context.Context, pattern string) error {
 		if ok, err := doublestar.Match(pattern, key); err != nil {
 			return err
 		} else if ok {
-			delete(b.objects, key)
+			b.drop(key)
 		}
 	}
 	return nil
@@ -116,20 +230,123 @@ func (b *InMem) ObjectSizes() map[string]int64 {
 // Close implements Bucket.
 func (*InMem) Close() error { return nil }
 
-func (b *InMem) store(name string, data []byte, opts *WriteOptions) {
+func (b *InMem) store(name string, data []byte, opts *WriteOptions, exclusive bool) error {
+	b.ops.Add(1)
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	b.objects[name] = &inMemObject{
+	prev, exists := b.objects[name]
+	if exists && exclusive {
+		return ErrExists
+	}
+	if err := b.checkQuota(prev, int64(len(data))); err != nil {
+		return err
+	}
+
+	modTime := opts.GetModTime()
+	if modTime.IsZero() {
+		modTime = b.config.Clock()
+	}
+
+	obj := &inMemObject{
 		data: data,
 		info: MetaInfo{
 			Name:        name,
 			Size:        int64(len(data)),
-			ModTime:     time.Now(),
+			ModTime:     modTime,
 			ContentType: opts.GetContentType(),
 			Metadata:    opts.GetMetadata(),
 		},
 	}
+	b.put(name, obj)
+	return nil
+}
+
+// put stores an object as the current version.
+// Must be called with the lock held.
+func (b *InMem) put(name string, obj *inMemObject) {
+	b.objects[name] = obj
+	b.addPending(name, obj)
+	b.addVersion(name, obj)
+}
+
+// drop removes the current version of an object.
+// Must be called with the lock held.
+func (b *InMem) drop(name string) {
+	if _, ok := b.objects[name]; !ok {
+		return
+	}
+
+	delete(b.objects, name)
+	b.addPending(name, nil)
+	b.addVersion(name, &inMemObject{
+		info:    MetaInfo{Name: name, ModTime: b.config.Clock()},
+		deleted: true,
+	})
+}
+
+// checkQuota checks if an object of the given size can replace prev, which
+// may be nil. Must be called with the lock held.
+func (b *InMem) checkQuota(prev *inMemObject, size int64) error {
+	if n := b.config.MaxObjects; n > 0 && prev == nil && len(b.objects) >= n {
+		return ErrQuotaExceeded
+	}
+	if n := b.config.MaxBytes; n > 0 {
+		total := size
+		for _, obj := range b.objects {
+			if obj != prev {
+				total += obj.Size()
+			}
+		}
+		if total > n {
+			return ErrQuotaExceeded
+		}
+	}
+	return nil
+}
+
+// addPending schedules a change to become visible in listings, obj is nil
+// for removals. Must be called with the lock held.
+func (b *InMem) addPending(name string, obj *inMemObject) {
+	if b.listed == nil {
+		return
+	}
+	b.pending = append(b.pending, inMemChange{
+		name: name,
+		obj:  obj,
+		time: b.config.Clock(),
+		op:   b.ops.Load(),
+	})
+}
+
+// applyPending applies all due changes to the listing view, in order.
+// Must be called with the lock held.
+func (b *InMem) applyPending() {
+	now, ops := b.config.Clock(), b.ops.Load()
+
+	n := 0
+	for _, c := range b.pending {
+		if now.Sub(c.time) < b.config.ListingDelay || ops-c.op < int64(b.config.ListingLag) {
+			break
+		}
+		if c.obj == nil {
+			delete(b.listed, c.name)
+		} else {
+			b.listed[c.name] = c.obj
+		}
+		n++
+	}
+	b.pending = b.pending[n:]
+}
+
+// syncListing makes all objects immediately visible in listings.
+// Must be called with the lock held.
+func (b *InMem) syncListing() {
+	if b.listed == nil {
+		return
+	}
+	b.listed = maps.Clone(b.objects)
+	b.pending = nil
 }
 
 // --------------------------------------------------------
@@ -137,12 +354,22 @@ func (b *InMem) store(name string, data []byte, opts *WriteOptions) {
 type inMemObject struct {
 	data []byte
 	info MetaInfo
+
+	version string
+	deleted bool
 }
 
 func (o *inMemObject) Size() int64 {
 	return int64(len(o.data))
 }
 
+type inMemChange struct {
+	name string
+	obj  *inMemObject
+	time time.Time
+	op   int64
+}
+
 type inMemReader struct{ *bytes.Reader }
 
 func (*inMemReader) Close() error { return nil }
@@ -155,6 +382,8 @@ type inMemWriter struct {
 	bucket *InMem
 	name   string
 	opts   *WriteOptions
+
+	exclusive bool
 }
 
 func (w *inMemWriter) Discard() error {
@@ -170,7 +399,10 @@ func (w *inMemWriter) Commit() error {
 	default:
 	}
 
-	w.bucket.store(w.name, w.Bytes(), w.opts)
+	if err := w.bucket.store(w.name, w.Bytes(), w.opts, w.exclusive); err != nil {
+		_ = w.Discard()
+		return err
+	}
 	return w.Discard()
 }
 
@@ -0,0 +1,271 @@
+package bfs
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// FixtureManifest is the name of the sidecar manifest file which stores
+// content types and metadata of objects loaded and dumped by InMem.
+const FixtureManifest = ".bfs-manifest.json"
+
+type fixtureManifest map[string]fixtureManifestEntry
+
+type fixtureManifestEntry struct {
+	ContentType string   `json:"contentType,omitempty"`
+	Metadata    Metadata `json:"metadata,omitempty"`
+}
+
+// InMemSnapshot is a point-in-time copy of the objects stored in an InMem
+// bucket.
+type InMemSnapshot struct {
+	objects  map[string]*inMemObject
+	versions map[string][]*inMemObject
+}
+
+// Snapshot returns a snapshot of the current state of the bucket. Snapshots
+// are cheap, as objects are never modified in place.
+func (b *InMem) Snapshot() *InMemSnapshot {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return &InMemSnapshot{
+		objects:  maps.Clone(b.objects),
+		versions: maps.Clone(b.versions),
+	}
+}
+
+// Restore restores the bucket to the state of a snapshot. Snapshots can be
+// restored multiple times. Restored objects are immediately visible in
+// listings.
+func (b *InMem) Restore(s *InMemSnapshot) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.objects = maps.Clone(s.objects)
+	if b.objects == nil {
+		b.objects = make(map[string]*inMemObject)
+	}
+	if b.versions != nil {
+		b.versions = maps.Clone(s.versions)
+		if b.versions == nil {
+			b.versions = make(map[string][]*inMemObject)
+		}
+	}
+	b.syncListing()
+}
+
+// LoadDir populates the bucket with files from a local directory, see LoadFS.
+func (b *InMem) LoadDir(dir string) error {
+	return b.LoadFS(os.DirFS(dir))
+}
+
+// LoadFS populates the bucket with all regular files from fsys. Content types
+// and metadata are read from an optional FixtureManifest file in the root of
+// fsys.
+func (b *InMem) LoadFS(fsys fs.FS) error {
+	var objects []*inMemObject
+	manifest := make(fixtureManifest)
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		if name == FixtureManifest {
+			return json.Unmarshal(data, &manifest)
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, newInMemObject(name, data, fi.ModTime()))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	b.load(objects, manifest)
+	return nil
+}
+
+// LoadTar populates the bucket with all regular files from a tar archive.
+// Content types and metadata are read from an optional FixtureManifest entry.
+func (b *InMem) LoadTar(r io.Reader) error {
+	var objects []*inMemObject
+	manifest := make(fixtureManifest)
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(hdr.Name)
+		if !fs.ValidPath(name) {
+			return fmt.Errorf("%w %q", ErrInvalidName, hdr.Name)
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+
+		if name == FixtureManifest {
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				return err
+			}
+			continue
+		}
+		objects = append(objects, newInMemObject(name, data, hdr.ModTime))
+	}
+
+	b.load(objects, manifest)
+	return nil
+}
+
+// DumpDir writes all objects to a local directory, including a
+// FixtureManifest file with content types and metadata.
+func (b *InMem) DumpDir(dir string) error {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	objects, manifest := b.dump()
+	for _, obj := range objects {
+		name := filepath.FromSlash(obj.info.Name)
+		if err := root.MkdirAll(filepath.Dir(name), 0777); err != nil {
+			return err
+		}
+		if err := root.WriteFile(name, obj.data, 0666); err != nil {
+			return err
+		}
+		if err := root.Chtimes(name, obj.info.ModTime, obj.info.ModTime); err != nil {
+			return err
+		}
+	}
+
+	if len(manifest) == 0 {
+		return nil
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return root.WriteFile(FixtureManifest, data, 0666)
+}
+
+// DumpTar writes all objects to a tar archive, including a FixtureManifest
+// entry with content types and metadata.
+func (b *InMem) DumpTar(w io.Writer) error {
+	tw := tar.NewWriter(w)
+	objects, manifest := b.dump()
+
+	if len(manifest) != 0 {
+		data, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeTarEntry(tw, FixtureManifest, data, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	for _, obj := range objects {
+		if err := writeTarEntry(tw, obj.info.Name, obj.data, obj.info.ModTime); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// load stores objects and applies the manifest. Loaded objects are
+// immediately visible in listings.
+func (b *InMem) load(objects []*inMemObject, manifest fixtureManifest) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, obj := range objects {
+		if ent, ok := manifest[obj.info.Name]; ok {
+			obj.info.ContentType = ent.ContentType
+			obj.info.Metadata = NormMetadata(ent.Metadata)
+		}
+		b.put(obj.info.Name, obj)
+	}
+	b.syncListing()
+}
+
+// dump returns all objects, sorted by name, and a manifest.
+func (b *InMem) dump() ([]*inMemObject, fixtureManifest) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	objects := make([]*inMemObject, 0, len(b.objects))
+	manifest := make(fixtureManifest)
+	for _, name := range slices.Sorted(maps.Keys(b.objects)) {
+		obj := b.objects[name]
+		objects = append(objects, obj)
+
+		if obj.info.ContentType != "" || len(obj.info.Metadata) != 0 {
+			manifest[name] = fixtureManifestEntry{
+				ContentType: obj.info.ContentType,
+				Metadata:    obj.info.Metadata,
+			}
+		}
+	}
+	return objects, manifest
+}
+
+func newInMemObject(name string, data []byte, modTime time.Time) *inMemObject {
+	return &inMemObject{
+		data: data,
+		info: MetaInfo{
+			Name:    name,
+			Size:    int64(len(data)),
+			ModTime: modTime,
+		},
+	}
+}
+
+func writeTarEntry(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     int64(len(data)),
+		Mode:     0644,
+		ModTime:  modTime,
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
@@ -0,0 +1,133 @@
+package bfs_test
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/bsm/bfs"
+)
+
+func TestInMem_Snapshot(t *testing.T) {
+	ctx := t.Context()
+	bucket := bfs.NewInMem()
+	if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), nil); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+
+	snap := bucket.Snapshot()
+	if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("changed"), nil); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if err := bfs.WriteObject(ctx, bucket, "b.txt", []byte("data"), nil); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if exp, got := map[string]int64{"a.txt": 7, "b.txt": 4}, bucket.ObjectSizes(); !reflect.DeepEqual(exp, got) {
+		t.Errorf("Expected %v, got %v", exp, got)
+	}
+
+	for range 2 {
+		bucket.Restore(snap)
+		if exp, got := map[string]int64{"a.txt": 4}, bucket.ObjectSizes(); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if err := bucket.Remove(ctx, "a.txt"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+	}
+}
+
+func TestInMem_Fixtures(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"a.txt":     {Data: []byte("plain"), ModTime: modTime},
+		"b/c.json":  {Data: []byte(`{"ok":true}`), ModTime: modTime},
+		"b/d/e.bin": {Data: []byte{1, 2, 3}, ModTime: modTime},
+		bfs.FixtureManifest: {Data: []byte(`{
+			"b/c.json": {"contentType": "application/json", "metadata": {"x-owner": "tests"}}
+		}`)},
+	}
+
+	assertFixtures := func(t *testing.T, bucket *bfs.InMem) {
+		t.Helper()
+
+		if exp, got := map[string]int64{"a.txt": 5, "b/c.json": 11, "b/d/e.bin": 3}, bucket.ObjectSizes(); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		info, err := bucket.Head(t.Context(), "b/c.json")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := "application/json", info.ContentType; exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := (bfs.Metadata{"X-Owner": "tests"}), info.Metadata; !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := modTime, info.ModTime; !exp.Equal(got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	}
+
+	t.Run("loads fs", func(t *testing.T) {
+		bucket := bfs.NewInMem()
+		if err := bucket.LoadFS(fsys); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		assertFixtures(t, bucket)
+	})
+
+	t.Run("dumps and loads dirs", func(t *testing.T) {
+		src := bfs.NewInMem()
+		if err := src.LoadFS(fsys); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		dir := t.TempDir()
+		if err := src.DumpDir(dir); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		bucket := bfs.NewInMem()
+		if err := bucket.LoadDir(dir); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		assertFixtures(t, bucket)
+	})
+
+	t.Run("dumps and loads tars", func(t *testing.T) {
+		src := bfs.NewInMem()
+		if err := src.LoadFS(fsys); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		var buf bytes.Buffer
+		if err := src.DumpTar(&buf); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		bucket := bfs.NewInMem()
+		if err := bucket.LoadTar(&buf); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		assertFixtures(t, bucket)
+
+		r, err := bucket.Open(t.Context(), "b/d/e.bin")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		defer r.Close()
+
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := []byte{1, 2, 3}, data; !bytes.Equal(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+}
@@ -1,15 +1,183 @@
 package bfs_test
 
 import (
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/bsm/bfs"
-	"github.com/bsm/bfs/testdata/lint"
+	"github.com/bsm/bfs/bfstest"
 )
 
 func TestInMem(t *testing.T) {
 	bucket := bfs.NewInMem()
-	support := lint.Supports{Metadata: true}
-	lint.Common(t, bucket, support)
-	lint.Slow(t, bucket, support)
+	support := bfstest.Supports{Metadata: true, UnicodeKeys: true, ConcurrentWrites: true}
+	bfstest.Common(t, bucket, support)
+	bfstest.Slow(t, bucket, support)
+}
+
+func TestInMemWithConfig(t *testing.T) {
+	ctx := t.Context()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	bucket := bfs.NewInMemWithConfig(&bfs.InMemConfig{
+		Clock: func() time.Time { return now },
+	})
+
+	for _, name := range []string{"c.txt", "a.txt", "d/e.txt", "b.txt"} {
+		if err := bfs.WriteObject(ctx, bucket, name, []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		now = now.Add(time.Hour)
+	}
+
+	explicit := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	if err := bfs.WriteObject(ctx, bucket, "f.txt", []byte("data"), &bfs.WriteOptions{ModTime: explicit}); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+
+	iter, err := bucket.Glob(ctx, "**")
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	defer iter.Close()
+
+	var entries []string
+	for iter.Next() {
+		entries = append(entries, iter.Name()+"@"+iter.ModTime().Format(time.DateTime))
+	}
+	if err := iter.Error(); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+
+	if exp := []string{
+		"a.txt@2024-01-02 04:04:05",
+		"b.txt@2024-01-02 06:04:05",
+		"c.txt@2024-01-02 03:04:05",
+		"d/e.txt@2024-01-02 05:04:05",
+		"f.txt@2020-06-01 00:00:00",
+	}; !reflect.DeepEqual(exp, entries) {
+		t.Errorf("Expected %v, got %v", exp, entries)
+	}
+}
+
+func TestInMem_Quotas(t *testing.T) {
+	ctx := t.Context()
+
+	t.Run("limits objects", func(t *testing.T) {
+		bucket := bfs.NewInMemWithConfig(&bfs.InMemConfig{MaxObjects: 2})
+		for _, name := range []string{"a.txt", "b.txt", "a.txt"} {
+			if err := bfs.WriteObject(ctx, bucket, name, []byte("data"), nil); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+		}
+		if exp, got := bfs.ErrQuotaExceeded, bfs.WriteObject(ctx, bucket, "c.txt", []byte("data"), nil); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := map[string]int64{"a.txt": 4, "b.txt": 4}, bucket.ObjectSizes(); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("limits bytes", func(t *testing.T) {
+		bucket := bfs.NewInMemWithConfig(&bfs.InMemConfig{MaxBytes: 10})
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("123456"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := bfs.ErrQuotaExceeded, bfs.WriteObject(ctx, bucket, "b.txt", []byte("12345"), nil); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("12"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bfs.WriteObject(ctx, bucket, "b.txt", []byte("12345"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := map[string]int64{"a.txt": 2, "b.txt": 5}, bucket.ObjectSizes(); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+}
+
+func TestInMem_EventualConsistency(t *testing.T) {
+	ctx := t.Context()
+
+	globNames := func(t *testing.T, bucket bfs.Bucket) []string {
+		t.Helper()
+
+		iter, err := bucket.Glob(ctx, "**")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		defer iter.Close()
+
+		names := []string{}
+		for iter.Next() {
+			names = append(names, iter.Name())
+		}
+		if err := iter.Error(); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		return names
+	}
+
+	t.Run("delays listings", func(t *testing.T) {
+		now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		bucket := bfs.NewInMemWithConfig(&bfs.InMemConfig{
+			Clock:        func() time.Time { return now },
+			ListingDelay: time.Minute,
+		})
+
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := []string{}, globNames(t, bucket); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if _, err := bucket.Head(ctx, "a.txt"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		now = now.Add(time.Minute)
+		if exp, got := []string{"a.txt"}, globNames(t, bucket); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		if err := bucket.Remove(ctx, "a.txt"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := []string{"a.txt"}, globNames(t, bucket); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		now = now.Add(time.Minute)
+		if exp, got := []string{}, globNames(t, bucket); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("lags listings", func(t *testing.T) {
+		bucket := bfs.NewInMemWithConfig(&bfs.InMemConfig{ListingLag: 2})
+
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := []string{}, globNames(t, bucket); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := []string{"a.txt"}, globNames(t, bucket); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("restores consistently", func(t *testing.T) {
+		bucket := bfs.NewInMemWithConfig(&bfs.InMemConfig{ListingLag: 100})
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		bucket.Restore(bucket.Snapshot())
+		if exp, got := []string{"a.txt"}, globNames(t, bucket); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
 }
@@ -0,0 +1,142 @@
+package bfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+)
+
+// ListVersions implements Versioned. It returns errors.ErrUnsupported unless
+// versioning is enabled via InMemConfig.
+func (b *InMem) ListVersions(_ context.Context, name string) ([]*VersionInfo, error) {
+	b.ops.Add(1)
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.versions == nil {
+		return nil, errors.ErrUnsupported
+	}
+
+	versions := b.versions[name]
+	if len(versions) == 0 {
+		return nil, ErrNotFound
+	}
+
+	infos := make([]*VersionInfo, 0, len(versions))
+	for i := len(versions) - 1; i >= 0; i-- {
+		obj := versions[i]
+		infos = append(infos, &VersionInfo{
+			MetaInfo:       obj.info,
+			VersionID:      obj.version,
+			IsLatest:       i == len(versions)-1,
+			IsDeleteMarker: obj.deleted,
+		})
+	}
+	return infos, nil
+}
+
+// HeadVersion implements Versioned. It returns errors.ErrUnsupported unless
+// versioning is enabled via InMemConfig.
+func (b *InMem) HeadVersion(_ context.Context, name, versionID string) (*MetaInfo, error) {
+	b.ops.Add(1)
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	obj, err := b.findVersion(name, versionID)
+	if err != nil {
+		return nil, err
+	}
+
+	info := obj.info
+	return &info, nil
+}
+
+// OpenVersion implements Versioned. It returns errors.ErrUnsupported unless
+// versioning is enabled via InMemConfig.
+func (b *InMem) OpenVersion(_ context.Context, name, versionID string) (Reader, error) {
+	b.ops.Add(1)
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	obj, err := b.findVersion(name, versionID)
+	if err != nil {
+		return nil, err
+	}
+	return &inMemReader{
+		Reader: bytes.NewReader(obj.data),
+	}, nil
+}
+
+// RemoveVersion implements Versioned. It returns errors.ErrUnsupported unless
+// versioning is enabled via InMemConfig. Removing the latest version makes
+// the previous version current.
+func (b *InMem) RemoveVersion(_ context.Context, name, versionID string) error {
+	b.ops.Add(1)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.versions == nil {
+		return errors.ErrUnsupported
+	}
+
+	versions := b.versions[name]
+	pos := slices.IndexFunc(versions, func(obj *inMemObject) bool { return obj.version == versionID })
+	if pos < 0 {
+		return ErrNotFound
+	}
+
+	// versions are shared with snapshots, never modify in place
+	versions = slices.Delete(slices.Clone(versions), pos, pos+1)
+	if len(versions) == 0 {
+		delete(b.versions, name)
+	} else {
+		b.versions[name] = versions
+	}
+
+	if pos != len(versions) {
+		return nil
+	}
+
+	if n := len(versions); n != 0 && !versions[n-1].deleted {
+		b.objects[name] = versions[n-1]
+		b.addPending(name, versions[n-1])
+	} else if _, ok := b.objects[name]; ok {
+		delete(b.objects, name)
+		b.addPending(name, nil)
+	}
+	return nil
+}
+
+// addVersion appends a version, obj is assigned a new version ID.
+// Must be called with the lock held.
+func (b *InMem) addVersion(name string, obj *inMemObject) {
+	if b.versions == nil {
+		return
+	}
+
+	b.seq++
+	obj.version = strconv.FormatInt(b.seq, 10)
+
+	// clip to prevent appending to slices shared with snapshots
+	b.versions[name] = append(slices.Clip(b.versions[name]), obj)
+}
+
+// findVersion finds a specific version of an object.
+// Must be called with the lock held.
+func (b *InMem) findVersion(name, versionID string) (*inMemObject, error) {
+	if b.versions == nil {
+		return nil, errors.ErrUnsupported
+	}
+
+	for _, obj := range b.versions[name] {
+		if obj.version == versionID {
+			if obj.deleted {
+				return nil, ErrNotFound
+			}
+			return obj, nil
+		}
+	}
+	return nil, ErrNotFound
+}
@@ -0,0 +1,261 @@
+package bfs_test
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/bsm/bfs"
+	"github.com/bsm/bfs/bfstest"
+)
+
+func TestInMem_Versioning(t *testing.T) {
+	ctx := t.Context()
+
+	listVersions := func(t *testing.T, bucket bfs.Versioned, name string) []string {
+		t.Helper()
+
+		versions, err := bucket.ListVersions(ctx, name)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		var ids []string
+		for _, v := range versions {
+			id := v.VersionID
+			if v.IsLatest {
+				id += "*"
+			}
+			if v.IsDeleteMarker {
+				id += "x"
+			}
+			ids = append(ids, id)
+		}
+		return ids
+	}
+
+	readVersion := func(t *testing.T, bucket bfs.Versioned, name, versionID string) string {
+		t.Helper()
+
+		r, err := bucket.OpenVersion(ctx, name, versionID)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		defer r.Close()
+
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		return string(data)
+	}
+
+	t.Run("lint", func(t *testing.T) {
+		bucket := bfs.NewInMemWithConfig(&bfs.InMemConfig{Versioning: true})
+		bfstest.Common(t, bucket, bfstest.Supports{Metadata: true, UnicodeKeys: true, ConcurrentWrites: true})
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		bucket := bfs.NewInMem()
+		if _, err := bucket.ListVersions(ctx, "a.txt"); !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("Expected %v, got %v", errors.ErrUnsupported, err)
+		}
+	})
+
+	t.Run("lists versions", func(t *testing.T) {
+		bucket := bfs.NewInMemWithConfig(&bfs.InMemConfig{Versioning: true})
+		if _, err := bucket.ListVersions(ctx, "a.txt"); err != bfs.ErrNotFound {
+			t.Errorf("Expected %v, got %v", bfs.ErrNotFound, err)
+		}
+
+		for _, data := range []string{"v1", "v2"} {
+			if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte(data), &bfs.WriteOptions{ContentType: "text/plain"}); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+		}
+		if exp, got := []string{"2*", "1"}, listVersions(t, bucket, "a.txt"); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := "v1", readVersion(t, bucket, "a.txt", "1"); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		info, err := bucket.HeadVersion(ctx, "a.txt", "1")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := int64(2), info.Size; exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := "text/plain", info.ContentType; exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if _, err := bucket.HeadVersion(ctx, "a.txt", "3"); err != bfs.ErrNotFound {
+			t.Errorf("Expected %v, got %v", bfs.ErrNotFound, err)
+		}
+	})
+
+	t.Run("creates delete markers", func(t *testing.T) {
+		bucket := bfs.NewInMemWithConfig(&bfs.InMemConfig{Versioning: true})
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("v1"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bucket.Remove(ctx, "a.txt"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if _, err := bucket.Head(ctx, "a.txt"); err != bfs.ErrNotFound {
+			t.Errorf("Expected %v, got %v", bfs.ErrNotFound, err)
+		}
+		if exp, got := []string{"2*x", "1"}, listVersions(t, bucket, "a.txt"); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if _, err := bucket.OpenVersion(ctx, "a.txt", "2"); err != bfs.ErrNotFound {
+			t.Errorf("Expected %v, got %v", bfs.ErrNotFound, err)
+		}
+
+		// removing the delete marker makes the object current again
+		if err := bucket.RemoveVersion(ctx, "a.txt", "2"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := map[string]int64{"a.txt": 2}, bucket.ObjectSizes(); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := []string{"1*"}, listVersions(t, bucket, "a.txt"); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("removes versions", func(t *testing.T) {
+		bucket := bfs.NewInMemWithConfig(&bfs.InMemConfig{Versioning: true})
+		for _, data := range []string{"v1", "v2", "v3"} {
+			if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte(data), nil); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+		}
+		snap := bucket.Snapshot()
+
+		if err := bucket.RemoveVersion(ctx, "a.txt", "2"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bucket.RemoveVersion(ctx, "a.txt", "3"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bucket.RemoveVersion(ctx, "a.txt", "3"); err != bfs.ErrNotFound {
+			t.Errorf("Expected %v, got %v", bfs.ErrNotFound, err)
+		}
+		if exp, got := []string{"1*"}, listVersions(t, bucket, "a.txt"); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		if err := bucket.RemoveVersion(ctx, "a.txt", "1"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := map[string]int64{}, bucket.ObjectSizes(); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		bucket.Restore(snap)
+		if exp, got := []string{"3*", "2", "1"}, listVersions(t, bucket, "a.txt"); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("restores versions", func(t *testing.T) {
+		bucket := bfs.NewInMemWithConfig(&bfs.InMemConfig{Versioning: true})
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("v1"), &bfs.WriteOptions{Metadata: bfs.Metadata{"X-Rev": "1"}}); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("v2"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		if err := bfs.RestoreVersion(ctx, bucket, "a.txt", "1"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := []string{"3*", "2", "1"}, listVersions(t, bucket, "a.txt"); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := "v1", readVersion(t, bucket, "a.txt", "3"); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		info, err := bucket.Head(ctx, "a.txt")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := (bfs.Metadata{"X-Rev": "1"}), info.Metadata; !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	for _, tc := range []struct {
+		name string
+		wrap func(bfs.Bucket) bfs.Bucket
+	}{
+		{"wrapped", func(b bfs.Bucket) bfs.Bucket { return bfs.Wrap(b, bfs.Middleware{}) }},
+		{"retry", func(b bfs.Bucket) bfs.Bucket { return bfs.WithRetry(b, nil) }},
+		{"sub", func(b bfs.Bucket) bfs.Bucket { return bfs.Sub(b, "x") }},
+		{"recorder", func(b bfs.Bucket) bfs.Bucket { return bfstest.NewRecorder(b) }},
+	} {
+		t.Run(tc.name+" forwards versions", func(t *testing.T) {
+			bucket := tc.wrap(bfs.NewInMemWithConfig(&bfs.InMemConfig{Versioning: true})).(bfs.Versioned)
+			for _, data := range []string{"v1", "v2"} {
+				if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte(data), nil); err != nil {
+					t.Fatal("Unexpected error", err)
+				}
+			}
+
+			versions, err := bucket.ListVersions(ctx, "a.txt")
+			if err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+			if exp, got := 2, len(versions); exp != got {
+				t.Fatalf("Expected %v, got %v", exp, got)
+			}
+			if exp, got := "a.txt", versions[1].Name; exp != got {
+				t.Errorf("Expected %v, got %v", exp, got)
+			}
+			if info, err := bucket.HeadVersion(ctx, "a.txt", versions[1].VersionID); err != nil {
+				t.Fatal("Unexpected error", err)
+			} else if exp, got := "a.txt", info.Name; exp != got {
+				t.Errorf("Expected %v, got %v", exp, got)
+			}
+
+			if err := bfs.RestoreVersion(ctx, bucket, "a.txt", versions[1].VersionID); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+			if exp, got := "v1", readVersion(t, bucket, "a.txt", "3"); exp != got {
+				t.Errorf("Expected %v, got %v", exp, got)
+			}
+			if err := bucket.RemoveVersion(ctx, "a.txt", "3"); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+		})
+	}
+
+	t.Run("forwards unsupported", func(t *testing.T) {
+		bucket := bfs.Wrap(bfs.NewInMem(), bfs.Middleware{}).(bfs.Versioned)
+		if _, err := bucket.ListVersions(ctx, "a.txt"); !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("Expected %v, got %v", errors.ErrUnsupported, err)
+		}
+	})
+
+	t.Run("read-only rejects changes", func(t *testing.T) {
+		base := bfs.NewInMemWithConfig(&bfs.InMemConfig{Versioning: true})
+		if err := bfs.WriteObject(ctx, base, "a.txt", []byte("v1"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		bucket := bfs.ReadOnly(base).(bfs.Versioned)
+		if exp, got := "v1", readVersion(t, bucket, "a.txt", "1"); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if err := bucket.RemoveVersion(ctx, "a.txt", "1"); !errors.Is(err, bfs.ErrReadOnly) {
+			t.Errorf("Expected %v, got %v", bfs.ErrReadOnly, err)
+		}
+		if err := bfs.RestoreVersion(ctx, bucket, "a.txt", "1"); !errors.Is(err, bfs.ErrReadOnly) {
+			t.Errorf("Expected %v, got %v", bfs.ErrReadOnly, err)
+		}
+	})
+}
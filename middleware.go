@@ -0,0 +1,368 @@
+package bfs
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// GlobFunc is the signature of Bucket.Glob.
+type GlobFunc func(ctx context.Context, pattern string) (Iterator, error)
+
+// HeadFunc is the signature of Bucket.Head.
+type HeadFunc func(ctx context.Context, name string) (*MetaInfo, error)
+
+// OpenFunc is the signature of Bucket.Open.
+type OpenFunc func(ctx context.Context, name string) (Reader, error)
+
+// CreateFunc is the signature of Bucket.Create.
+type CreateFunc func(ctx context.Context, name string, opts *WriteOptions) (Writer, error)
+
+// RemoveFunc is the signature of Bucket.Remove.
+type RemoveFunc func(ctx context.Context, name string) error
+
+// CopyFunc is the signature of the optional Copy extension.
+type CopyFunc func(ctx context.Context, src, dst string) error
+
+// RemoveAllFunc is the signature of the optional RemoveAll extension.
+type RemoveAllFunc func(ctx context.Context, pattern string) error
+
+// ListVersionsFunc is the signature of Versioned.ListVersions.
+type ListVersionsFunc func(ctx context.Context, name string) ([]*VersionInfo, error)
+
+// HeadVersionFunc is the signature of Versioned.HeadVersion.
+type HeadVersionFunc func(ctx context.Context, name, versionID string) (*MetaInfo, error)
+
+// OpenVersionFunc is the signature of Versioned.OpenVersion.
+type OpenVersionFunc func(ctx context.Context, name, versionID string) (Reader, error)
+
+// RemoveVersionFunc is the signature of Versioned.RemoveVersion.
+type RemoveVersionFunc func(ctx context.Context, name, versionID string) error
+
+// RestoreVersionFunc is the signature of the optional RestoreVersion extension.
+type RestoreVersionFunc func(ctx context.Context, name, versionID string) error
+
+// Middleware intercepts bucket operations. Each hook receives the arguments
+// of the call together with the next handler in the chain, it may inspect or
+// modify arguments and results, or return without calling next at all.
+// Hooks which are not set pass calls through unchanged.
+type Middleware struct {
+	Glob      func(ctx context.Context, pattern string, next GlobFunc) (Iterator, error)
+	Head      func(ctx context.Context, name string, next HeadFunc) (*MetaInfo, error)
+	Open      func(ctx context.Context, name string, next OpenFunc) (Reader, error)
+	Create    func(ctx context.Context, name string, opts *WriteOptions, next CreateFunc) (Writer, error)
+	Remove    func(ctx context.Context, name string, next RemoveFunc) error
+	Copy      func(ctx context.Context, src, dst string, next CopyFunc) error
+	RemoveAll func(ctx context.Context, pattern string, next RemoveAllFunc) error
+
+	// Version hooks intercept the methods of Versioned buckets.
+	ListVersions   func(ctx context.Context, name string, next ListVersionsFunc) ([]*VersionInfo, error)
+	HeadVersion    func(ctx context.Context, name, versionID string, next HeadVersionFunc) (*MetaInfo, error)
+	OpenVersion    func(ctx context.Context, name, versionID string, next OpenVersionFunc) (Reader, error)
+	RemoveVersion  func(ctx context.Context, name, versionID string, next RemoveVersionFunc) error
+	RestoreVersion func(ctx context.Context, name, versionID string, next RestoreVersionFunc) error
+
+	// Commit and Discard intercept the lifecycle of writers returned by
+	// Create. They receive the context and name passed to Create.
+	Commit  func(ctx context.Context, name string, next func() error) error
+	Discard func(ctx context.Context, name string, next func() error) error
+}
+
+// Wrap wraps a bucket with middlewares. The first middleware is the
+// outermost, i.e. it is the first to intercept each call.
+//
+// The returned bucket always implements Versioned and exposes the optional
+// Copy, RemoveAll, CreateNew, RestoreVersion and Notify extensions. If these
+// are not supported by the underlying bucket, calls return
+// errors.ErrUnsupported (unless intercepted by a middleware) and helpers,
+// such as CopyObject and RemoveAll, fall back on their generic
+// implementations. Calls to CreateNew pass through the Create hooks.
+func Wrap(bucket Bucket, mws ...Middleware) Bucket {
+	if len(mws) == 0 {
+		return bucket
+	}
+
+	w := &wrapped{
+		Bucket: bucket,
+		mws:    mws,
+		glob:   bucket.Glob,
+		head:   bucket.Head,
+		open:   bucket.Open,
+		create: bucket.Create,
+		remove: bucket.Remove,
+		copy: func(context.Context, string, string) error {
+			return errors.ErrUnsupported
+		},
+		removeAll: func(context.Context, string) error {
+			return errors.ErrUnsupported
+		},
+		createNew: func(context.Context, string, *WriteOptions) (Writer, error) {
+			return nil, errors.ErrUnsupported
+		},
+		listVersions: func(context.Context, string) ([]*VersionInfo, error) {
+			return nil, errors.ErrUnsupported
+		},
+		headVersion: func(context.Context, string, string) (*MetaInfo, error) {
+			return nil, errors.ErrUnsupported
+		},
+		openVersion: func(context.Context, string, string) (Reader, error) {
+			return nil, errors.ErrUnsupported
+		},
+		removeVersion: func(context.Context, string, string) error {
+			return errors.ErrUnsupported
+		},
+		restoreVersion: func(context.Context, string, string) error {
+			return errors.ErrUnsupported
+		},
+	}
+	if b, ok := bucket.(supportsCopy); ok {
+		w.copy = b.Copy
+	}
+	if b, ok := bucket.(supportsRemoveAll); ok {
+		w.removeAll = b.RemoveAll
+	}
+	if b, ok := bucket.(supportsCreateNew); ok {
+		w.createNew = b.CreateNew
+	}
+	if b, ok := bucket.(Versioned); ok {
+		w.listVersions = b.ListVersions
+		w.headVersion = b.HeadVersion
+		w.openVersion = b.OpenVersion
+		w.removeVersion = b.RemoveVersion
+	}
+	if b, ok := bucket.(supportsRestoreVersion); ok {
+		w.restoreVersion = b.RestoreVersion
+	}
+
+	for i := len(mws) - 1; i >= 0; i-- {
+		mw := mws[i]
+
+		if hook, next := mw.Glob, w.glob; hook != nil {
+			w.glob = func(ctx context.Context, pattern string) (Iterator, error) {
+				return hook(ctx, pattern, next)
+			}
+		}
+		if hook, next := mw.Head, w.head; hook != nil {
+			w.head = func(ctx context.Context, name string) (*MetaInfo, error) {
+				return hook(ctx, name, next)
+			}
+		}
+		if hook, next := mw.Open, w.open; hook != nil {
+			w.open = func(ctx context.Context, name string) (Reader, error) {
+				return hook(ctx, name, next)
+			}
+		}
+		if hook, next := mw.Create, w.create; hook != nil {
+			w.create = func(ctx context.Context, name string, opts *WriteOptions) (Writer, error) {
+				return hook(ctx, name, opts, next)
+			}
+		}
+		if hook, next := mw.Create, w.createNew; hook != nil {
+			w.createNew = func(ctx context.Context, name string, opts *WriteOptions) (Writer, error) {
+				return hook(ctx, name, opts, next)
+			}
+		}
+		if hook, next := mw.Remove, w.remove; hook != nil {
+			w.remove = func(ctx context.Context, name string) error {
+				return hook(ctx, name, next)
+			}
+		}
+		if hook, next := mw.Copy, w.copy; hook != nil {
+			w.copy = func(ctx context.Context, src, dst string) error {
+				return hook(ctx, src, dst, next)
+			}
+		}
+		if hook, next := mw.RemoveAll, w.removeAll; hook != nil {
+			w.removeAll = func(ctx context.Context, pattern string) error {
+				return hook(ctx, pattern, next)
+			}
+		}
+		if hook, next := mw.ListVersions, w.listVersions; hook != nil {
+			w.listVersions = func(ctx context.Context, name string) ([]*VersionInfo, error) {
+				return hook(ctx, name, next)
+			}
+		}
+		if hook, next := mw.HeadVersion, w.headVersion; hook != nil {
+			w.headVersion = func(ctx context.Context, name, versionID string) (*MetaInfo, error) {
+				return hook(ctx, name, versionID, next)
+			}
+		}
+		if hook, next := mw.OpenVersion, w.openVersion; hook != nil {
+			w.openVersion = func(ctx context.Context, name, versionID string) (Reader, error) {
+				return hook(ctx, name, versionID, next)
+			}
+		}
+		if hook, next := mw.RemoveVersion, w.removeVersion; hook != nil {
+			w.removeVersion = func(ctx context.Context, name, versionID string) error {
+				return hook(ctx, name, versionID, next)
+			}
+		}
+		if hook, next := mw.RestoreVersion, w.restoreVersion; hook != nil {
+			w.restoreVersion = func(ctx context.Context, name, versionID string) error {
+				return hook(ctx, name, versionID, next)
+			}
+		}
+		if mw.Commit != nil || mw.Discard != nil {
+			w.hasWriterHooks = true
+		}
+	}
+	return w
+}
+
+type wrapped struct {
+	Bucket
+
+	mws            []Middleware
+	hasWriterHooks bool
+
+	glob      GlobFunc
+	head      HeadFunc
+	open      OpenFunc
+	create    CreateFunc
+	remove    RemoveFunc
+	copy      CopyFunc
+	removeAll RemoveAllFunc
+	createNew CreateFunc
+
+	listVersions   ListVersionsFunc
+	headVersion    HeadVersionFunc
+	openVersion    OpenVersionFunc
+	removeVersion  RemoveVersionFunc
+	restoreVersion RestoreVersionFunc
+}
+
+// Glob implements Bucket.
+func (w *wrapped) Glob(ctx context.Context, pattern string) (Iterator, error) {
+	return w.glob(ctx, pattern)
+}
+
+// Head implements Bucket.
+func (w *wrapped) Head(ctx context.Context, name string) (*MetaInfo, error) {
+	return w.head(ctx, name)
+}
+
+// Open implements Bucket.
+func (w *wrapped) Open(ctx context.Context, name string) (Reader, error) {
+	return w.open(ctx, name)
+}
+
+// Create implements Bucket.
+func (w *wrapped) Create(ctx context.Context, name string, opts *WriteOptions) (Writer, error) {
+	wr, err := w.create(ctx, name, opts)
+	if err != nil || !w.hasWriterHooks {
+		return wr, err
+	}
+	return w.wrapWriter(ctx, name, wr), nil
+}
+
+// CreateNew implements Bucket extension.
+func (w *wrapped) CreateNew(ctx context.Context, name string, opts *WriteOptions) (Writer, error) {
+	wr, err := w.createNew(ctx, name, opts)
+	if err != nil || !w.hasWriterHooks {
+		return wr, err
+	}
+	return w.wrapWriter(ctx, name, wr), nil
+}
+
+// Remove implements Bucket.
+func (w *wrapped) Remove(ctx context.Context, name string) error {
+	return w.remove(ctx, name)
+}
+
+// Copy implements Bucket extension.
+func (w *wrapped) Copy(ctx context.Context, src, dst string) error {
+	return w.copy(ctx, src, dst)
+}
+
+// RemoveAll implements Bucket extension.
+func (w *wrapped) RemoveAll(ctx context.Context, pattern string) error {
+	return w.removeAll(ctx, pattern)
+}
+
+// Notify implements Bucket extension.
+func (w *wrapped) Notify(ctx context.Context, pattern string) (<-chan struct{}, error) {
+	notifier, ok := w.Bucket.(supportsNotify)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return notifier.Notify(ctx, pattern)
+}
+
+// ListVersions implements Versioned.
+func (w *wrapped) ListVersions(ctx context.Context, name string) ([]*VersionInfo, error) {
+	return w.listVersions(ctx, name)
+}
+
+// HeadVersion implements Versioned.
+func (w *wrapped) HeadVersion(ctx context.Context, name, versionID string) (*MetaInfo, error) {
+	return w.headVersion(ctx, name, versionID)
+}
+
+// OpenVersion implements Versioned.
+func (w *wrapped) OpenVersion(ctx context.Context, name, versionID string) (Reader, error) {
+	return w.openVersion(ctx, name, versionID)
+}
+
+// RemoveVersion implements Versioned.
+func (w *wrapped) RemoveVersion(ctx context.Context, name, versionID string) error {
+	return w.removeVersion(ctx, name, versionID)
+}
+
+// RestoreVersion implements Versioned extension.
+func (w *wrapped) RestoreVersion(ctx context.Context, name, versionID string) error {
+	return w.restoreVersion(ctx, name, versionID)
+}
+
+func (w *wrapped) wrapWriter(ctx context.Context, name string, wr Writer) Writer {
+	ww := &wrappedWriter{
+		Writer:  wr,
+		commit:  wr.Commit,
+		discard: wr.Discard,
+	}
+
+	for i := len(w.mws) - 1; i >= 0; i-- {
+		mw := w.mws[i]
+
+		if hook, next := mw.Commit, ww.commit; hook != nil {
+			ww.commit = func() error { return hook(ctx, name, next) }
+		}
+		if hook, next := mw.Discard, ww.discard; hook != nil {
+			ww.discard = func() error { return hook(ctx, name, next) }
+		}
+	}
+	return ww
+}
+
+// wrappedWriter applies Commit and Discard hooks at most once per writer,
+// e.g. a deferred Discard after a successful Commit is not intercepted.
+type wrappedWriter struct {
+	Writer
+
+	commit  func() error
+	discard func() error
+	once    sync.Once
+}
+
+// Commit implements Writer.
+func (w *wrappedWriter) Commit() error {
+	if w.close() {
+		return w.Writer.Commit() // already closed, let the writer report
+	}
+	return w.commit()
+}
+
+// Discard implements Writer.
+func (w *wrappedWriter) Discard() error {
+	if w.close() {
+		return w.Writer.Discard() // already closed, let the writer report
+	}
+	return w.discard()
+}
+
+// close marks the writer as closed and reports whether
+// it had been closed before.
+func (w *wrappedWriter) close() bool {
+	closed := true
+	w.once.Do(func() { closed = false })
+	return closed
+}
@@ -0,0 +1,159 @@
+package bfs_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bsm/bfs"
+	"github.com/bsm/bfs/bfstest"
+)
+
+func TestWrap(t *testing.T) {
+	t.Run("lint", func(t *testing.T) {
+		bucket := bfs.Wrap(bfs.NewInMem(), bfs.Middleware{}, bfs.Middleware{})
+		bfstest.Common(t, bucket, bfstest.Supports{Metadata: true})
+	})
+
+	t.Run("intercepts", func(t *testing.T) {
+		ctx := t.Context()
+
+		var calls []string
+		record := func(tag string) bfs.Middleware {
+			return bfs.Middleware{
+				Head: func(ctx context.Context, name string, next bfs.HeadFunc) (*bfs.MetaInfo, error) {
+					calls = append(calls, tag+":head:"+name)
+					return next(ctx, name)
+				},
+				Create: func(ctx context.Context, name string, opts *bfs.WriteOptions, next bfs.CreateFunc) (bfs.Writer, error) {
+					calls = append(calls, tag+":create:"+name)
+					return next(ctx, name, opts)
+				},
+				Commit: func(ctx context.Context, name string, next func() error) error {
+					calls = append(calls, tag+":commit:"+name)
+					return next()
+				},
+				Discard: func(ctx context.Context, name string, next func() error) error {
+					calls = append(calls, tag+":discard:"+name)
+					return next()
+				},
+				RemoveAll: func(ctx context.Context, pattern string, next bfs.RemoveAllFunc) error {
+					calls = append(calls, tag+":removeall:"+pattern)
+					return next(ctx, pattern)
+				},
+			}
+		}
+		bucket := bfs.Wrap(bfs.NewInMem(), record("outer"), record("inner"))
+
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if _, err := bucket.Head(ctx, "a.txt"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bfs.RemoveAll(ctx, bucket, "*.txt"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		exp := []string{
+			"outer:create:a.txt", "inner:create:a.txt",
+			"outer:commit:a.txt", "inner:commit:a.txt",
+			"outer:head:a.txt", "inner:head:a.txt",
+			"outer:removeall:*.txt", "inner:removeall:*.txt",
+		}
+		if !reflect.DeepEqual(exp, calls) {
+			t.Errorf("Expected %v, got %v", exp, calls)
+		}
+	})
+
+	t.Run("short-circuits", func(t *testing.T) {
+		ctx := t.Context()
+		errDenied := errors.New("denied")
+		bucket := bfs.Wrap(bfs.NewInMem(), bfs.Middleware{
+			Commit: func(context.Context, string, func() error) error {
+				return errDenied
+			},
+		})
+
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), nil); !errors.Is(err, errDenied) {
+			t.Errorf("Expected %v, got %v", errDenied, err)
+		}
+		if _, err := bucket.Head(ctx, "a.txt"); !errors.Is(err, bfs.ErrNotFound) {
+			t.Errorf("Expected %v, got %v", bfs.ErrNotFound, err)
+		}
+	})
+
+	t.Run("keeps extensions reachable", func(t *testing.T) {
+		ctx := t.Context()
+		bucket := bfs.Wrap(bfs.NewInMem(), bfs.Middleware{})
+
+		// InMem does not support Copy natively
+		if err := bfs.WriteObject(ctx, bucket, "src.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		copier := bucket.(interface {
+			Copy(context.Context, string, string) error
+		})
+		if err := copier.Copy(ctx, "src.txt", "dst.txt"); !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("Expected %v, got %v", errors.ErrUnsupported, err)
+		}
+		if err := bfs.CopyObject(ctx, bucket, "src.txt", "dst.txt", nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		// InMem supports RemoveAll natively
+		remover := bucket.(interface {
+			RemoveAll(context.Context, string) error
+		})
+		if err := remover.RemoveAll(ctx, "*.txt"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if _, err := bucket.Head(ctx, "dst.txt"); !errors.Is(err, bfs.ErrNotFound) {
+			t.Errorf("Expected %v, got %v", bfs.ErrNotFound, err)
+		}
+	})
+
+	t.Run("forwards exclusive creation", func(t *testing.T) {
+		ctx := t.Context()
+
+		var calls []string
+		bucket := bfs.Wrap(bfs.NewInMem(), bfs.Middleware{
+			Create: func(ctx context.Context, name string, opts *bfs.WriteOptions, next bfs.CreateFunc) (bfs.Writer, error) {
+				calls = append(calls, "create:"+name)
+				return next(ctx, name, opts)
+			},
+			Commit: func(ctx context.Context, name string, next func() error) error {
+				calls = append(calls, "commit:"+name)
+				return next()
+			},
+		})
+		exclusive := bucket.(interface {
+			CreateNew(context.Context, string, *bfs.WriteOptions) (bfs.Writer, error)
+		})
+
+		for _, exp := range []error{nil, bfs.ErrExists} {
+			w, err := exclusive.CreateNew(ctx, "a.txt", nil)
+			if err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+			if err := w.Commit(); !errors.Is(err, exp) {
+				t.Errorf("Expected %v, got %v", exp, err)
+			}
+		}
+		if exp := []string{
+			"create:a.txt", "commit:a.txt",
+			"create:a.txt", "commit:a.txt",
+		}; !reflect.DeepEqual(exp, calls) {
+			t.Errorf("Expected %v, got %v", exp, calls)
+		}
+
+		// unsupported by the underlying bucket
+		exclusive = bfs.Wrap(struct{ bfs.Bucket }{bfs.NewInMem()}, bfs.Middleware{}).(interface {
+			CreateNew(context.Context, string, *bfs.WriteOptions) (bfs.Writer, error)
+		})
+		if _, err := exclusive.CreateNew(ctx, "a.txt", nil); !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("Expected %v, got %v", errors.ErrUnsupported, err)
+		}
+	})
+}
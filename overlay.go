@@ -0,0 +1,230 @@
+package bfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// whiteoutPrefix marks removed objects in the top layer of an overlay.
+const whiteoutPrefix = ".wh."
+
+// Overlay returns a bucket which layers a writable top bucket over one or
+// more lower buckets. Reads fall through the layers in order, Glob merges
+// and de-duplicates names across all layers.
+//
+// All modifications are applied to the top layer only. Removals of objects
+// which exist in lower layers are recorded as whiteout markers in the top
+// layer (e.g. "dir/.wh.file.txt" for "dir/file.txt"), which hide the lower
+// objects from view. Names with a ".wh." prefix are therefore reserved and
+// rejected with ErrInvalidName.
+//
+// Closing the overlay closes all layers.
+func Overlay(top Bucket, lower ...Bucket) Bucket {
+	return &overlayBucket{top: top, lower: lower}
+}
+
+type overlayBucket struct {
+	top   Bucket
+	lower []Bucket
+}
+
+// Glob implements Bucket.
+func (b *overlayBucket) Glob(ctx context.Context, pattern string) (Iterator, error) {
+	seen := make(map[string]struct{})
+	var entries []*inMemObject
+
+	collect := func(bucket Bucket, pattern string, fn func(name string) bool) error {
+		iter, err := bucket.Glob(ctx, pattern)
+		if err != nil {
+			return err
+		}
+		defer iter.Close()
+
+		for iter.Next() {
+			name := iter.Name()
+			if _, ok := seen[name]; ok || !fn(name) {
+				continue
+			}
+			seen[name] = struct{}{}
+			entries = append(entries, &inMemObject{info: MetaInfo{
+				Name:    name,
+				Size:    iter.Size(),
+				ModTime: iter.ModTime(),
+			}})
+		}
+		return iter.Error()
+	}
+
+	if err := collect(b.top, pattern, func(name string) bool { return !isWhiteout(name) }); err != nil {
+		return nil, err
+	}
+
+	if len(b.lower) != 0 {
+		whiteouts, err := b.whiteouts(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, bucket := range b.lower {
+			if err := collect(bucket, pattern, func(name string) bool {
+				_, removed := whiteouts[name]
+				return !removed
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	slices.SortFunc(entries, func(a, b *inMemObject) int {
+		return strings.Compare(a.info.Name, b.info.Name)
+	})
+	return &inMemIterator{entries: entries, pos: -1}, nil
+}
+
+// Head implements Bucket.
+func (b *overlayBucket) Head(ctx context.Context, name string) (*MetaInfo, error) {
+	bucket, err := b.find(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return bucket.Head(ctx, name)
+}
+
+// Open implements Bucket.
+func (b *overlayBucket) Open(ctx context.Context, name string) (Reader, error) {
+	bucket, err := b.find(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return bucket.Open(ctx, name)
+}
+
+// Create implements Bucket.
+func (b *overlayBucket) Create(ctx context.Context, name string, opts *WriteOptions) (Writer, error) {
+	if isWhiteout(name) {
+		return nil, fmt.Errorf("%w %q", ErrInvalidName, name)
+	}
+
+	w, err := b.top.Create(ctx, name, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &overlayWriter{Writer: w, ctx: ctx, top: b.top, name: name}, nil
+}
+
+// Remove implements Bucket.
+func (b *overlayBucket) Remove(ctx context.Context, name string) error {
+	if isWhiteout(name) {
+		return fmt.Errorf("%w %q", ErrInvalidName, name)
+	}
+
+	if err := b.top.Remove(ctx, name); err != nil {
+		return err
+	}
+
+	for _, bucket := range b.lower {
+		if _, err := bucket.Head(ctx, name); err == nil {
+			return WriteObject(ctx, b.top, whiteoutName(name), nil, nil)
+		} else if !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close implements Bucket.
+func (b *overlayBucket) Close() error {
+	err := b.top.Close()
+	for _, bucket := range b.lower {
+		err = errors.Join(err, bucket.Close())
+	}
+	return err
+}
+
+// find returns the top-most layer which contains the named object.
+func (b *overlayBucket) find(ctx context.Context, name string) (Bucket, error) {
+	if isWhiteout(name) {
+		return nil, ErrNotFound
+	}
+
+	if _, err := b.top.Head(ctx, name); err == nil {
+		return b.top, nil
+	} else if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	if len(b.lower) == 0 {
+		return nil, ErrNotFound
+	}
+
+	if _, err := b.top.Head(ctx, whiteoutName(name)); err == nil {
+		return nil, ErrNotFound
+	} else if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	for _, bucket := range b.lower {
+		if _, err := bucket.Head(ctx, name); err == nil {
+			return bucket, nil
+		} else if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// whiteouts returns the names of all removed objects.
+func (b *overlayBucket) whiteouts(ctx context.Context) (map[string]struct{}, error) {
+	iter, err := b.top.Glob(ctx, "**/"+whiteoutPrefix+"*")
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	names := make(map[string]struct{})
+	for iter.Next() {
+		if name, ok := fromWhiteout(iter.Name()); ok {
+			names[name] = struct{}{}
+		}
+	}
+	return names, iter.Error()
+}
+
+// --------------------------------------------------------------------
+
+type overlayWriter struct {
+	Writer
+
+	ctx  context.Context
+	top  Bucket
+	name string
+}
+
+func (w *overlayWriter) Commit() error {
+	if err := w.Writer.Commit(); err != nil {
+		return err
+	}
+	return w.top.Remove(w.ctx, whiteoutName(w.name))
+}
+
+// --------------------------------------------------------------------
+
+func isWhiteout(name string) bool {
+	return strings.HasPrefix(path.Base(name), whiteoutPrefix)
+}
+
+func whiteoutName(name string) string {
+	dir, base := path.Split(name)
+	return dir + whiteoutPrefix + base
+}
+
+func fromWhiteout(name string) (string, bool) {
+	dir, base := path.Split(name)
+	if !strings.HasPrefix(base, whiteoutPrefix) {
+		return "", false
+	}
+	return dir + strings.TrimPrefix(base, whiteoutPrefix), true
+}
@@ -0,0 +1,104 @@
+package bfs_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bsm/bfs"
+	"github.com/bsm/bfs/bfstest"
+)
+
+func TestOverlay(t *testing.T) {
+	t.Run("lint", func(t *testing.T) {
+		bucket := bfs.Overlay(bfs.NewInMem(), bfs.NewInMem(), bfs.NewInMem())
+		bfstest.Common(t, bucket, bfstest.Supports{Metadata: true})
+	})
+
+	t.Run("layers buckets", func(t *testing.T) {
+		ctx := t.Context()
+		top, mid, bottom := bfs.NewInMem(), bfs.NewInMem(), bfs.NewInMem()
+		bucket := bfs.Overlay(top, mid, bottom)
+
+		for name, data := range map[string]string{"a.txt": "mid", "b/c.txt": "mid"} {
+			if err := bfs.WriteObject(ctx, mid, name, []byte(data), nil); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+		}
+		for name, data := range map[string]string{"a.txt": "bottom", "b/d.txt": "bottom"} {
+			if err := bfs.WriteObject(ctx, bottom, name, []byte(data), nil); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+		}
+
+		info, err := bucket.Head(ctx, "a.txt")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := int64(3), info.Size; exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("top"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bfs.WriteObject(ctx, bucket, "e.txt", []byte("top!"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bucket.Remove(ctx, "b/c.txt"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if _, err := bucket.Head(ctx, "b/c.txt"); !errors.Is(err, bfs.ErrNotFound) {
+			t.Errorf("Expected %v, got %v", bfs.ErrNotFound, err)
+		}
+
+		if exp, got := map[string]int64{"a.txt": 3, "b/d.txt": 6, "e.txt": 4}, globSizes(t, bucket, "**"); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := map[string]int64{"a.txt": 3, "e.txt": 4, "b/.wh.c.txt": 0}, top.ObjectSizes(); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := map[string]int64{"a.txt": 3, "b/c.txt": 3}, mid.ObjectSizes(); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		// re-creating clears whiteout
+		if err := bfs.WriteObject(ctx, bucket, "b/c.txt", []byte("top"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := map[string]int64{"a.txt": 3, "b/c.txt": 3, "b/d.txt": 6, "e.txt": 4}, globSizes(t, bucket, "**"); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("rejects whiteout names", func(t *testing.T) {
+		ctx := t.Context()
+		bucket := bfs.Overlay(bfs.NewInMem(), bfs.NewInMem())
+
+		if _, err := bucket.Create(ctx, "dir/.wh.a.txt", nil); !errors.Is(err, bfs.ErrInvalidName) {
+			t.Errorf("Expected %v, got %v", bfs.ErrInvalidName, err)
+		}
+		if _, err := bucket.Head(ctx, "dir/.wh.a.txt"); !errors.Is(err, bfs.ErrNotFound) {
+			t.Errorf("Expected %v, got %v", bfs.ErrNotFound, err)
+		}
+	})
+}
+
+func globSizes(t *testing.T, bucket bfs.Bucket, pattern string) map[string]int64 {
+	t.Helper()
+
+	iter, err := bucket.Glob(t.Context(), pattern)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	defer iter.Close()
+
+	sizes := make(map[string]int64)
+	for iter.Next() {
+		sizes[iter.Name()] = iter.Size()
+	}
+	if err := iter.Error(); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	return sizes
+}
@@ -0,0 +1,130 @@
+package bfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// ErrReadOnly is returned by read-only buckets on attempts to modify objects.
+var ErrReadOnly = errors.New("bfs: bucket is read-only")
+
+// ErrExists is returned when attempting to overwrite an existing object
+// through a write-once bucket.
+var ErrExists = errors.New("bfs: object already exists")
+
+// ErrNoExclusiveCreate is returned by operations which require the bucket to
+// support exclusive creation natively.
+var ErrNoExclusiveCreate = fmt.Errorf("bfs: exclusive creation is not supported: %w", errors.ErrUnsupported)
+
+// ReadOnly wraps a bucket and rejects all modifications with ErrReadOnly.
+func ReadOnly(bucket Bucket) Bucket {
+	return Wrap(bucket, Middleware{
+		Create: func(context.Context, string, *WriteOptions, CreateFunc) (Writer, error) {
+			return nil, ErrReadOnly
+		},
+		Remove: func(context.Context, string, RemoveFunc) error {
+			return ErrReadOnly
+		},
+		Copy: func(context.Context, string, string, CopyFunc) error {
+			return ErrReadOnly
+		},
+		RemoveAll: func(context.Context, string, RemoveAllFunc) error {
+			return ErrReadOnly
+		},
+		RemoveVersion: func(context.Context, string, string, RemoveVersionFunc) error {
+			return ErrReadOnly
+		},
+		RestoreVersion: func(context.Context, string, string, RestoreVersionFunc) error {
+			return ErrReadOnly
+		},
+	})
+}
+
+// WriteOnce wraps a bucket and refuses to overwrite existing objects with
+// ErrExists. Objects can still be removed.
+//
+// Existence is checked with Head on Create, so that writes to existing
+// objects are rejected early. If the bucket supports exclusive creation
+// natively (such as InMem, bfsfs, bfss3 or bfsgs, also when wrapped by
+// middlewares), the final check on Commit is atomic. Otherwise, existence is
+// checked again with Head on Commit. This fallback is not atomic, concurrent
+// writers may still overwrite each other.
+func WriteOnce(bucket Bucket) Bucket {
+	return Wrap(bucket, Middleware{
+		Create: func(ctx context.Context, name string, opts *WriteOptions, next CreateFunc) (Writer, error) {
+			if err := checkNotExists(ctx, bucket, name); err != nil {
+				return nil, err
+			}
+
+			if b, ok := bucket.(supportsCreateNew); ok {
+				if w, err := b.CreateNew(ctx, name, opts); !errors.Is(err, errors.ErrUnsupported) {
+					return w, err
+				}
+			}
+
+			w, err := next(ctx, name, opts)
+			if err != nil {
+				return nil, err
+			}
+			return &writeOnceWriter{Writer: w, ctx: ctx, bucket: bucket, name: name}, nil
+		},
+		Copy: func(context.Context, string, string, CopyFunc) error {
+			// native copies cannot be made exclusive,
+			// make CopyObject fall back on Create
+			return errors.ErrUnsupported
+		},
+		RestoreVersion: func(context.Context, string, string, RestoreVersionFunc) error {
+			// same for RestoreVersion
+			return errors.ErrUnsupported
+		},
+	})
+}
+
+// writeOnceWriter re-checks existence on Commit.
+type writeOnceWriter struct {
+	Writer
+
+	ctx    context.Context
+	bucket Bucket
+	name   string
+}
+
+func (w *writeOnceWriter) Commit() error {
+	if err := checkNotExists(w.ctx, w.bucket, w.name); err != nil {
+		_ = w.Discard()
+		return err
+	}
+	return w.Writer.Commit()
+}
+
+// checkNotExists returns ErrExists if the object exists.
+func checkNotExists(ctx context.Context, bucket Bucket, name string) error {
+	if _, err := bucket.Head(ctx, name); err == nil {
+		return ErrExists
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// parseFlag parses and removes a boolean flag from the query.
+func parseFlag(query url.Values, key string) (bool, error) {
+	if !query.Has(key) {
+		return false, nil
+	}
+
+	s := query.Get(key)
+	query.Del(key)
+	if s == "" {
+		return true, nil
+	}
+
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, errors.New("bfs: invalid " + key + " value")
+	}
+	return v, nil
+}
@@ -0,0 +1,180 @@
+package bfs_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bsm/bfs"
+	"github.com/bsm/bfs/bfstest"
+)
+
+func TestReadOnly(t *testing.T) {
+	t.Run("rejects modifications", func(t *testing.T) {
+		ctx := t.Context()
+		base := bfs.NewInMem()
+		if err := bfs.WriteObject(ctx, base, "a.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		bucket := bfs.ReadOnly(base)
+		if _, err := bucket.Create(ctx, "b.txt", nil); !errors.Is(err, bfs.ErrReadOnly) {
+			t.Errorf("Expected %v, got %v", bfs.ErrReadOnly, err)
+		}
+		if err := bucket.Remove(ctx, "a.txt"); !errors.Is(err, bfs.ErrReadOnly) {
+			t.Errorf("Expected %v, got %v", bfs.ErrReadOnly, err)
+		}
+		if err := bfs.CopyObject(ctx, bucket, "a.txt", "b.txt", nil); !errors.Is(err, bfs.ErrReadOnly) {
+			t.Errorf("Expected %v, got %v", bfs.ErrReadOnly, err)
+		}
+		if err := bfs.RemoveAll(ctx, bucket, "**"); !errors.Is(err, bfs.ErrReadOnly) {
+			t.Errorf("Expected %v, got %v", bfs.ErrReadOnly, err)
+		}
+
+		if exp, got := map[string]int64{"a.txt": 4}, base.ObjectSizes(); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if _, err := bucket.Head(ctx, "a.txt"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+	})
+
+	t.Run("resolves", func(t *testing.T) {
+		bucket, err := bfs.Connect(t.Context(), "mem://?readonly=true")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if _, err := bucket.Create(t.Context(), "a.txt", nil); !errors.Is(err, bfs.ErrReadOnly) {
+			t.Errorf("Expected %v, got %v", bfs.ErrReadOnly, err)
+		}
+
+		// guards are applied outside of retries
+		bucket, err = bfs.Connect(t.Context(), "mem://?readonly=true&retry_attempts=3")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if _, err := bucket.Create(t.Context(), "a.txt", nil); !errors.Is(err, bfs.ErrReadOnly) {
+			t.Errorf("Expected %v, got %v", bfs.ErrReadOnly, err)
+		}
+
+		if _, err := bfs.Connect(t.Context(), "mem://?readonly=x"); err == nil {
+			t.Errorf("Expected error")
+		}
+	})
+}
+
+func TestWriteOnce(t *testing.T) {
+	t.Run("lint", func(t *testing.T) {
+		bucket := bfs.WriteOnce(bfs.NewInMem())
+		bfstest.Common(t, bucket, bfstest.Supports{Metadata: true})
+	})
+
+	t.Run("lint wrapped", func(t *testing.T) {
+		bucket := bfs.WriteOnce(bfs.Wrap(bfs.NewInMem(), bfs.Middleware{}))
+		bfstest.Common(t, bucket, bfstest.Supports{Metadata: true})
+	})
+
+	t.Run("lint fallback", func(t *testing.T) {
+		bucket := bfs.WriteOnce(struct{ bfs.Bucket }{bfs.NewInMem()})
+		bfstest.Common(t, bucket, bfstest.Supports{Metadata: true})
+	})
+
+	t.Run("applies middlewares", func(t *testing.T) {
+		bucket := bfs.WriteOnce(bfs.ReadOnly(bfs.NewInMem()))
+		if _, err := bucket.Create(t.Context(), "a.txt", nil); !errors.Is(err, bfs.ErrReadOnly) {
+			t.Errorf("Expected %v, got %v", bfs.ErrReadOnly, err)
+		}
+	})
+
+	for _, tc := range []struct {
+		name string
+		base func() bfs.Bucket
+	}{
+		{"native", func() bfs.Bucket { return bfs.NewInMem() }},
+		{"sub", func() bfs.Bucket { return bfs.Sub(bfs.NewInMem(), "x") }},
+		{"wrapped", func() bfs.Bucket { return bfs.Wrap(bfs.NewInMem(), bfs.Middleware{}) }},
+		{"retry", func() bfs.Bucket { return bfs.WithRetry(bfs.NewInMem(), nil) }},
+		{"fallback", func() bfs.Bucket { return struct{ bfs.Bucket }{bfs.NewInMem()} }},
+		{"wrapped fallback", func() bfs.Bucket { return bfs.Wrap(struct{ bfs.Bucket }{bfs.NewInMem()}, bfs.Middleware{}) }},
+	} {
+		t.Run(tc.name+" refuses overwrites", func(t *testing.T) {
+			ctx := t.Context()
+			bucket := bfs.WriteOnce(tc.base())
+
+			w1, err := bucket.Create(ctx, "a.txt", nil)
+			if err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+			defer w1.Discard()
+
+			w2, err := bucket.Create(ctx, "a.txt", nil)
+			if err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+			defer w2.Discard()
+
+			if _, err := w1.Write([]byte("first")); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+			if _, err := w2.Write([]byte("second")); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+			if err := w1.Commit(); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+			if err := w2.Commit(); !errors.Is(err, bfs.ErrExists) {
+				t.Errorf("Expected %v, got %v", bfs.ErrExists, err)
+			}
+
+			if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("third"), nil); !errors.Is(err, bfs.ErrExists) {
+				t.Errorf("Expected %v, got %v", bfs.ErrExists, err)
+			}
+			if err := bfs.WriteObject(ctx, bucket, "b.txt", []byte("data"), nil); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+			if err := bfs.CopyObject(ctx, bucket, "a.txt", "b.txt", nil); !errors.Is(err, bfs.ErrExists) {
+				t.Errorf("Expected %v, got %v", bfs.ErrExists, err)
+			}
+
+			if info, err := bucket.Head(ctx, "a.txt"); err != nil {
+				t.Fatal("Unexpected error", err)
+			} else if exp, got := int64(5), info.Size; exp != got {
+				t.Errorf("Expected %v, got %v", exp, got)
+			}
+
+			// removals are allowed
+			if err := bucket.Remove(ctx, "a.txt"); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+			if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("fourth"), nil); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+		})
+	}
+
+	t.Run("resolves", func(t *testing.T) {
+		ctx := t.Context()
+		bucket, err := bfs.Connect(ctx, "mem://?writeonce")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), nil); !errors.Is(err, bfs.ErrExists) {
+			t.Errorf("Expected %v, got %v", bfs.ErrExists, err)
+		}
+
+		// guards are applied outside of retries
+		bucket, err = bfs.Connect(ctx, "mem://?writeonce&retry_attempts=3")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if _, err := bucket.Create(ctx, "a.txt", nil); !errors.Is(err, bfs.ErrExists) {
+			t.Errorf("Expected %v, got %v", bfs.ErrExists, err)
+		}
+	})
+}
@@ -0,0 +1,312 @@
+package bfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ReplicaConfig configures replicated buckets.
+type ReplicaConfig struct {
+	// WriteQuorum is the minimum number of replicas which must accept a
+	// write for it to succeed. Default: all replicas.
+	WriteQuorum int
+	// OnReplicaError is called when a write has met the quorum but has
+	// failed on individual replicas. Optional.
+	OnReplicaError func(name string, err *ReplicaError)
+}
+
+func (c *ReplicaConfig) norm(n int) *ReplicaConfig {
+	if c.WriteQuorum < 1 || c.WriteQuorum > n {
+		c.WriteQuorum = n
+	}
+	return c
+}
+
+// ReplicaError combines the errors of individual replicas.
+type ReplicaError struct {
+	// Errors contains an entry for each replica, in the order in which
+	// replicas were passed to Replicate, nil entries indicate success.
+	Errors []error
+	// Committed lists the indexes of replicas which have committed a write
+	// before the write quorum was found to be missed.
+	Committed []int
+}
+
+// Error implements error.
+func (e *ReplicaError) Error() string {
+	var parts []string
+	for i, err := range e.Errors {
+		if err != nil {
+			parts = append(parts, fmt.Sprintf("replica %d: %v", i, err))
+		}
+	}
+	return "bfs: " + strings.Join(parts, "; ")
+}
+
+// Unwrap returns the non-nil replica errors.
+func (e *ReplicaError) Unwrap() []error {
+	var errs []error
+	for _, err := range e.Errors {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// newReplicaError returns a *ReplicaError if any of the errors is non-nil.
+func newReplicaError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return &ReplicaError{Errors: errs}
+		}
+	}
+	return nil
+}
+
+// Replicate returns a bucket which replicates writes across multiple
+// buckets.
+//
+// Created objects are written to all replicas concurrently and are either
+// committed to all replicas or discarded on all, depending on whether the
+// configured write quorum can be met. Writes which meet the quorum succeed,
+// failures of individual replicas are reported to OnReplicaError. If fewer
+// than quorum replicas manage to commit, a *ReplicaError is returned which
+// lists the replicas that did commit. Please note that these are not rolled
+// back, a partial commit may therefore leave replicas out of sync.
+//
+// Reads are served by the first healthy replica, falling through to the
+// next replica on errors. Removals apply to all replicas. Failures of
+// individual replicas are reported as a *ReplicaError.
+//
+// Closing the bucket closes all replicas.
+func Replicate(replicas []Bucket, cfg *ReplicaConfig) (Bucket, error) {
+	if len(replicas) == 0 {
+		return nil, errors.New("bfs: at least one replica is required")
+	}
+
+	config := new(ReplicaConfig)
+	if cfg != nil {
+		*config = *cfg
+	}
+	return &replicaBucket{
+		replicas: replicas,
+		config:   config.norm(len(replicas)),
+	}, nil
+}
+
+type replicaBucket struct {
+	replicas []Bucket
+	config   *ReplicaConfig
+}
+
+// Glob implements Bucket.
+func (b *replicaBucket) Glob(ctx context.Context, pattern string) (Iterator, error) {
+	errs := make([]error, len(b.replicas))
+	for i, replica := range b.replicas {
+		iter, err := replica.Glob(ctx, pattern)
+		if err == nil {
+			return iter, nil
+		}
+		errs[i] = err
+	}
+	return nil, newReplicaError(errs)
+}
+
+// Head implements Bucket.
+func (b *replicaBucket) Head(ctx context.Context, name string) (*MetaInfo, error) {
+	errs := make([]error, len(b.replicas))
+	for i, replica := range b.replicas {
+		info, err := replica.Head(ctx, name)
+		if err == nil {
+			return info, nil
+		}
+		errs[i] = err
+	}
+	return nil, replicaReadError(errs)
+}
+
+// Open implements Bucket.
+func (b *replicaBucket) Open(ctx context.Context, name string) (Reader, error) {
+	errs := make([]error, len(b.replicas))
+	for i, replica := range b.replicas {
+		r, err := replica.Open(ctx, name)
+		if err == nil {
+			return r, nil
+		}
+		errs[i] = err
+	}
+	return nil, replicaReadError(errs)
+}
+
+// Create implements Bucket.
+func (b *replicaBucket) Create(ctx context.Context, name string, opts *WriteOptions) (Writer, error) {
+	w := &replicaWriter{
+		bucket:  b,
+		name:    name,
+		writers: make([]Writer, len(b.replicas)),
+		errs:    make([]error, len(b.replicas)),
+	}
+	for i, replica := range b.replicas {
+		w.writers[i], w.errs[i] = replica.Create(ctx, name, opts)
+	}
+
+	if err := w.checkQuorum(); err != nil {
+		_ = w.Discard()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Remove implements Bucket.
+func (b *replicaBucket) Remove(ctx context.Context, name string) error {
+	return b.each(func(replica Bucket) error {
+		return replica.Remove(ctx, name)
+	})
+}
+
+// RemoveAll implements Bucket extension.
+func (b *replicaBucket) RemoveAll(ctx context.Context, pattern string) error {
+	return b.each(func(replica Bucket) error {
+		return RemoveAll(ctx, replica, pattern)
+	})
+}
+
+// Close implements Bucket.
+func (b *replicaBucket) Close() error {
+	return b.each(func(replica Bucket) error {
+		return replica.Close()
+	})
+}
+
+// each calls fn concurrently for each replica.
+func (b *replicaBucket) each(fn func(Bucket) error) error {
+	errs := make([]error, len(b.replicas))
+
+	var wg sync.WaitGroup
+	for i, replica := range b.replicas {
+		wg.Go(func() { errs[i] = fn(replica) })
+	}
+	wg.Wait()
+
+	return newReplicaError(errs)
+}
+
+// replicaReadError returns ErrNotFound if the object could not be found on
+// any of the replicas, a combined error otherwise.
+func replicaReadError(errs []error) error {
+	for _, err := range errs {
+		if !errors.Is(err, ErrNotFound) {
+			return newReplicaError(errs)
+		}
+	}
+	return ErrNotFound
+}
+
+// --------------------------------------------------------------------
+
+type replicaWriter struct {
+	bucket  *replicaBucket
+	ctx     context.Context
+	name    string
+	writers []Writer
+	errs    []error
+	closed  bool
+}
+
+// Write implements Writer.
+func (w *replicaWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+
+	var wg sync.WaitGroup
+	for i, wr := range w.writers {
+		if w.errs[i] != nil {
+			continue
+		}
+		wg.Go(func() {
+			if _, err := wr.Write(p); err != nil {
+				w.errs[i] = err
+				_ = wr.Discard()
+			}
+		})
+	}
+	wg.Wait()
+
+	if err := w.checkQuorum(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Discard implements Writer.
+func (w *replicaWriter) Discard() error {
+	if w.closed {
+		return os.ErrClosed
+	}
+	w.closed = true
+
+	for i, wr := range w.writers {
+		if w.errs[i] == nil {
+			_ = wr.Discard()
+		}
+	}
+	return nil
+}
+
+// Commit implements Writer.
+func (w *replicaWriter) Commit() error {
+	if w.closed {
+		return os.ErrClosed
+	}
+	if err := w.checkQuorum(); err != nil {
+		_ = w.Discard()
+		return err
+	}
+	w.closed = true
+
+	var wg sync.WaitGroup
+	for i, wr := range w.writers {
+		if w.errs[i] == nil {
+			wg.Go(func() { w.errs[i] = wr.Commit() })
+		}
+	}
+	wg.Wait()
+
+	if err := w.checkQuorum(); err != nil {
+		var committed []int
+		for i, err := range w.errs {
+			if err == nil {
+				committed = append(committed, i)
+			}
+		}
+		return &ReplicaError{Errors: w.errs, Committed: committed}
+	}
+
+	// report failed replicas
+	fn := w.bucket.config.OnReplicaError
+	if fn != nil && slices.ContainsFunc(w.errs, func(err error) bool { return err != nil }) {
+		fn(w.name, &ReplicaError{Errors: w.errs})
+	}
+	return nil
+}
+
+// checkQuorum returns a combined error if quorum can no longer be met.
+func (w *replicaWriter) checkQuorum() error {
+	ok := 0
+	for _, err := range w.errs {
+		if err == nil {
+			ok++
+		}
+	}
+	if ok < w.bucket.config.WriteQuorum {
+		return newReplicaError(w.errs)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package bfs_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bsm/bfs"
+	"github.com/bsm/bfs/bfstest"
+)
+
+func TestReplicate(t *testing.T) {
+	errBroken := errors.New("broken")
+
+	t.Run("lint", func(t *testing.T) {
+		bucket, err := bfs.Replicate([]bfs.Bucket{bfs.NewInMem(), bfs.NewInMem()}, nil)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		bfstest.Common(t, bucket, bfstest.Supports{Metadata: true})
+	})
+
+	t.Run("validates", func(t *testing.T) {
+		if _, err := bfs.Replicate(nil, nil); err == nil {
+			t.Errorf("Expected error")
+		}
+	})
+
+	t.Run("writes to all", func(t *testing.T) {
+		ctx := t.Context()
+		r1, r2 := bfs.NewInMem(), bfs.NewInMem()
+		bucket, err := bfs.Replicate([]bfs.Bucket{r1, r2}, nil)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bfs.WriteObject(ctx, bucket, "b.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bucket.Remove(ctx, "b.txt"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		for _, r := range []*bfs.InMem{r1, r2} {
+			if exp, got := map[string]int64{"a.txt": 4}, r.ObjectSizes(); !reflect.DeepEqual(exp, got) {
+				t.Errorf("Expected %v, got %v", exp, got)
+			}
+		}
+	})
+
+	t.Run("reads from first healthy", func(t *testing.T) {
+		ctx := t.Context()
+		r1, r2 := bfs.NewInMem(), bfs.NewInMem()
+		if err := bfs.WriteObject(ctx, r2, "a.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		broken := &flakyBucket{Bucket: r1, failures: 100, err: errBroken}
+		bucket, err := bfs.Replicate([]bfs.Bucket{broken, r2}, nil)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		info, err := bucket.Head(ctx, "a.txt")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := int64(4), info.Size; exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		_, err = bucket.Head(ctx, "missing.txt")
+		if !errors.Is(err, errBroken) || !errors.Is(err, bfs.ErrNotFound) {
+			t.Errorf("Expected combined error, got %v", err)
+		}
+	})
+
+	t.Run("meets quorum", func(t *testing.T) {
+		ctx := t.Context()
+		r1, r2, r3 := bfs.NewInMem(), bfs.NewInMem(), bfs.NewInMem()
+		broken := &flakyBucket{Bucket: r3, failures: 100, err: errBroken}
+
+		var reported []error
+		bucket, err := bfs.Replicate([]bfs.Bucket{r1, r2, broken}, &bfs.ReplicaConfig{
+			WriteQuorum:    2,
+			OnReplicaError: func(_ string, err *bfs.ReplicaError) { reported = err.Errors },
+		})
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := []error{nil, nil, errBroken}, reported; !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := map[string]int64{"a.txt": 4}, r2.ObjectSizes(); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		err = bucket.Remove(ctx, "a.txt")
+		var rerr *bfs.ReplicaError
+		if !errors.As(err, &rerr) {
+			t.Fatalf("Expected ReplicaError, got %v", err)
+		}
+		if exp, got := []error{nil, nil, errBroken}, rerr.Errors; !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := "bfs: replica 2: broken", err.Error(); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("reports failed commits", func(t *testing.T) {
+		ctx := t.Context()
+		r1, r2 := bfs.NewInMem(), bfs.NewInMem()
+		broken := &failingCommitBucket{Bucket: r2, err: errBroken}
+
+		var reported []string
+		bucket, err := bfs.Replicate([]bfs.Bucket{r1, broken}, &bfs.ReplicaConfig{
+			WriteQuorum:    1,
+			OnReplicaError: func(name string, err *bfs.ReplicaError) { reported = append(reported, name+": "+err.Error()) },
+		})
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := []string{"a.txt: bfs: replica 1: broken"}, reported; !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := map[string]int64{"a.txt": 4}, r1.ObjectSizes(); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("discards all without quorum", func(t *testing.T) {
+		ctx := t.Context()
+		r1, r2 := bfs.NewInMem(), bfs.NewInMem()
+		broken := &flakyBucket{Bucket: r2, failures: 100, err: errBroken}
+		bucket, err := bfs.Replicate([]bfs.Bucket{r1, broken}, nil)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), nil); !errors.Is(err, errBroken) {
+			t.Errorf("Expected %v, got %v", errBroken, err)
+		}
+		if exp, got := map[string]int64{}, r1.ObjectSizes(); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("keeps partial commits without quorum", func(t *testing.T) {
+		ctx := t.Context()
+		r1, r2 := bfs.NewInMem(), bfs.NewInMem()
+		for _, r := range []*bfs.InMem{r1, r2} {
+			if err := bfs.WriteObject(ctx, r, "a.txt", []byte("old"), nil); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+		}
+
+		broken := &failingCommitBucket{Bucket: r2, err: errBroken}
+		bucket, err := bfs.Replicate([]bfs.Bucket{r1, broken}, nil)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		err = bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), nil)
+		var rerr *bfs.ReplicaError
+		if !errors.As(err, &rerr) {
+			t.Fatalf("Expected ReplicaError, got %v", err)
+		}
+		if exp, got := []int{0}, rerr.Committed; !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if !errors.Is(err, errBroken) {
+			t.Errorf("Expected %v, got %v", errBroken, err)
+		}
+
+		// replicas are out of sync, but no data is lost
+		if exp, got := map[string]int64{"a.txt": 4}, r1.ObjectSizes(); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := map[string]int64{"a.txt": 3}, r2.ObjectSizes(); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+}
+
+// failingCommitBucket creates writers which fail to commit.
+type failingCommitBucket struct {
+	bfs.Bucket
+
+	err error
+}
+
+func (b *failingCommitBucket) Create(ctx context.Context, name string, opts *bfs.WriteOptions) (bfs.Writer, error) {
+	w, err := b.Bucket.Create(ctx, name, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &failingCommitWriter{Writer: w, err: b.err}, nil
+}
+
+type failingCommitWriter struct {
+	bfs.Writer
+
+	err error
+}
+
+func (w *failingCommitWriter) Commit() error {
+	_ = w.Discard()
+	return w.err
+}
@@ -0,0 +1,359 @@
+package bfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryPolicy configures WithRetry.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts per operation,
+	// including the first one. Default: 3.
+	MaxAttempts int
+	// MinBackoff is the delay before the first retry, it doubles with
+	// every subsequent attempt. Default: 100ms.
+	MinBackoff time.Duration
+	// MaxBackoff caps the delay between attempts. Default: 5s.
+	MaxBackoff time.Duration
+	// Jitter is the fraction of each delay which is randomised, must be
+	// between 0 and 1. Use a negative value to disable. Default: 0.5.
+	Jitter float64
+	// Retryable reports whether an error is transient and the operation
+	// should be retried. Default: DefaultRetryable.
+	Retryable func(error) bool
+	// TempDir is used to spool written data. Default: os.TempDir().
+	TempDir string
+}
+
+func (p *RetryPolicy) norm() {
+	if p.MaxAttempts < 1 {
+		p.MaxAttempts = 3
+	}
+	if p.MinBackoff <= 0 {
+		p.MinBackoff = 100 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 5 * time.Second
+	}
+	if p.MaxBackoff < p.MinBackoff {
+		p.MaxBackoff = p.MinBackoff
+	}
+	if p.Jitter == 0 {
+		p.Jitter = 0.5
+	} else if p.Jitter < 0 {
+		p.Jitter = 0
+	} else if p.Jitter > 1 {
+		p.Jitter = 1
+	}
+	if p.Retryable == nil {
+		p.Retryable = DefaultRetryable
+	}
+}
+
+// backoff returns the delay before the given attempt (starting at 1).
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.MaxBackoff
+	if attempt < 32 {
+		if d := p.MinBackoff << (attempt - 1); d > 0 && d < delay {
+			delay = d
+		}
+	}
+	if p.Jitter > 0 {
+		delay -= time.Duration(p.Jitter * rand.Float64() * float64(delay))
+	}
+	return delay
+}
+
+// DefaultRetryable is the default retry classifier. It treats all errors as
+// transient, except for ErrNotFound, ErrExists, ErrReadOnly, ErrInvalidName,
+// ErrQuotaExceeded, errors.ErrUnsupported, permission errors and context
+// cancellations.
+func DefaultRetryable(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, ErrNotFound),
+		errors.Is(err, ErrExists),
+		errors.Is(err, ErrReadOnly),
+		errors.Is(err, ErrInvalidName),
+		errors.Is(err, ErrQuotaExceeded),
+		errors.Is(err, errors.ErrUnsupported),
+		errors.Is(err, os.ErrPermission),
+		errors.Is(err, os.ErrClosed),
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded):
+		return false
+	}
+	return true
+}
+
+// parseRetryPolicy extracts a retry policy from URL query parameters and
+// removes them from the query. It returns nil if retries are not enabled.
+func parseRetryPolicy(query url.Values) (*RetryPolicy, error) {
+	s := query.Get("retry_attempts")
+	if s == "" {
+		return nil, nil
+	}
+
+	var err error
+	policy := new(RetryPolicy)
+	if policy.MaxAttempts, err = strconv.Atoi(s); err != nil {
+		return nil, errors.New("bfs: invalid retry_attempts value")
+	}
+	if s := query.Get("retry_backoff"); s != "" {
+		if policy.MinBackoff, err = time.ParseDuration(s); err != nil {
+			return nil, errors.New("bfs: invalid retry_backoff value")
+		}
+	}
+	if s := query.Get("retry_max_backoff"); s != "" {
+		if policy.MaxBackoff, err = time.ParseDuration(s); err != nil {
+			return nil, errors.New("bfs: invalid retry_max_backoff value")
+		}
+	}
+	policy.TempDir = query.Get("tmpdir")
+
+	for _, k := range []string{"retry_attempts", "retry_backoff", "retry_max_backoff"} {
+		query.Del(k)
+	}
+	return policy, nil
+}
+
+// WithRetry wraps a bucket and retries operations which fail with transient
+// errors using exponential backoff with jitter.
+//
+// Head, Open, Remove, Copy and RemoveAll calls are retried as a whole. Glob
+// calls are retried and, when iterators fail mid-listing, listings are
+// restarted transparently, skipping entries up to the last returned one.
+// This relies on the bucket listing entries in a stable (e.g. sorted) order.
+// Writers spool data into a local temporary file and defer the creation of
+// the underlying object until Commit, which can then be replayed from the
+// spooled data.
+func WithRetry(bucket Bucket, policy *RetryPolicy) Bucket {
+	p := new(RetryPolicy)
+	if policy != nil {
+		*p = *policy
+	}
+	p.norm()
+
+	r := &retrier{policy: p}
+	return Wrap(bucket, Middleware{
+		Glob:      r.glob,
+		Head:      r.head,
+		Open:      r.open,
+		Create:    r.create,
+		Remove:    r.remove,
+		Copy:      r.copy,
+		RemoveAll: r.removeAll,
+	})
+}
+
+type retrier struct {
+	policy *RetryPolicy
+}
+
+// do calls fn until it succeeds, fails permanently or attempts are exhausted.
+func (r *retrier) do(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= r.policy.MaxAttempts || !r.policy.Retryable(err) {
+			return err
+		}
+		if err := r.wait(ctx, attempt); err != nil {
+			return err
+		}
+	}
+}
+
+// wait waits for the backoff of the given attempt or until ctx is done.
+func (r *retrier) wait(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(r.policy.backoff(attempt))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (r *retrier) glob(ctx context.Context, pattern string, next GlobFunc) (Iterator, error) {
+	var iter Iterator
+	err := r.do(ctx, func() (err error) {
+		iter, err = next(ctx, pattern)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &retryIterator{Iterator: iter, r: r, ctx: ctx, pattern: pattern, glob: next}, nil
+}
+
+func (r *retrier) head(ctx context.Context, name string, next HeadFunc) (*MetaInfo, error) {
+	var info *MetaInfo
+	err := r.do(ctx, func() (err error) {
+		info, err = next(ctx, name)
+		return
+	})
+	return info, err
+}
+
+func (r *retrier) open(ctx context.Context, name string, next OpenFunc) (Reader, error) {
+	var rd Reader
+	err := r.do(ctx, func() (err error) {
+		rd, err = next(ctx, name)
+		return
+	})
+	return rd, err
+}
+
+func (r *retrier) create(ctx context.Context, name string, opts *WriteOptions, next CreateFunc) (Writer, error) {
+	f, err := os.CreateTemp(r.policy.TempDir, "bfs-retry")
+	if err != nil {
+		return nil, err
+	}
+	return &retryWriter{File: f, r: r, ctx: ctx, name: name, opts: opts, create: next}, nil
+}
+
+func (r *retrier) remove(ctx context.Context, name string, next RemoveFunc) error {
+	return r.do(ctx, func() error { return next(ctx, name) })
+}
+
+func (r *retrier) copy(ctx context.Context, src, dst string, next CopyFunc) error {
+	return r.do(ctx, func() error { return next(ctx, src, dst) })
+}
+
+func (r *retrier) removeAll(ctx context.Context, pattern string, next RemoveAllFunc) error {
+	return r.do(ctx, func() error { return next(ctx, pattern) })
+}
+
+// --------------------------------------------------------------------
+
+type retryWriter struct {
+	*os.File
+
+	r      *retrier
+	ctx    context.Context
+	name   string
+	opts   *WriteOptions
+	create CreateFunc
+
+	closeOnce sync.Once
+}
+
+func (w *retryWriter) Discard() error {
+	err := os.ErrClosed
+	w.closeOnce.Do(func() {
+		defer os.Remove(w.Name())
+
+		err = w.Close()
+	})
+	return err
+}
+
+func (w *retryWriter) Commit() error {
+	err := os.ErrClosed
+	w.closeOnce.Do(func() {
+		defer os.Remove(w.Name())
+		defer w.Close()
+
+		if err = w.ctx.Err(); err != nil {
+			return
+		}
+		err = w.r.do(w.ctx, w.replay)
+	})
+	return err
+}
+
+// replay creates the object and writes the spooled data.
+func (w *retryWriter) replay() error {
+	if _, err := w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	dst, err := w.create(w.ctx, w.name, w.opts)
+	if err != nil {
+		return err
+	}
+	defer dst.Discard()
+
+	if _, err := io.Copy(dst, w.File); err != nil {
+		return err
+	}
+	return dst.Commit()
+}
+
+// --------------------------------------------------------------------
+
+type retryIterator struct {
+	Iterator
+
+	r       *retrier
+	ctx     context.Context
+	pattern string
+	glob    GlobFunc
+	last    string // the last returned name
+	skip    bool   // skip names up to last after a restart
+	attempt int
+	err     error
+}
+
+func (i *retryIterator) Next() bool {
+	for i.err == nil {
+		if i.Iterator.Next() {
+			name := i.Iterator.Name()
+			if i.skip {
+				if name == i.last {
+					i.skip = false
+					continue
+				} else if name < i.last {
+					continue
+				}
+				// last has been removed since
+				i.skip = false
+			}
+			i.last = name
+			return true
+		}
+
+		err := i.Iterator.Error()
+		if err == nil {
+			return false
+		}
+
+		// restart listing on transient errors
+		i.attempt++
+		if i.attempt >= i.r.policy.MaxAttempts || !i.r.policy.Retryable(err) {
+			i.err = err
+			return false
+		}
+		if err := i.r.wait(i.ctx, i.attempt); err != nil {
+			i.err = err
+			return false
+		}
+
+		iter, err := i.glob(i.ctx, i.pattern)
+		if err != nil {
+			i.err = err
+			return false
+		}
+		_ = i.Iterator.Close()
+		i.Iterator = iter
+		i.skip = i.last != ""
+	}
+	return false
+}
+
+func (i *retryIterator) Error() error {
+	if i.err != nil {
+		return i.err
+	}
+	return i.Iterator.Error()
+}
@@ -0,0 +1,270 @@
+package bfs_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bsm/bfs"
+	"github.com/bsm/bfs/bfstest"
+)
+
+func TestWithRetry(t *testing.T) {
+	errFlaky := errors.New("flaky")
+	policy := &bfs.RetryPolicy{MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	t.Run("lint", func(t *testing.T) {
+		bucket := bfs.WithRetry(bfs.NewInMem(), policy)
+		bfstest.Common(t, bucket, bfstest.Supports{Metadata: true})
+	})
+
+	t.Run("retries", func(t *testing.T) {
+		ctx := t.Context()
+		flaky := &flakyBucket{Bucket: bfs.NewInMem(), failures: 2, err: errFlaky}
+		bucket := bfs.WithRetry(flaky, policy)
+
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if _, err := bucket.Head(ctx, "a.txt"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bucket.Remove(ctx, "a.txt"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := map[string]int{"create": 3, "head": 3, "remove": 3}, flaky.calls; !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		flaky := &flakyBucket{Bucket: bfs.NewInMem(), failures: 5, err: errFlaky}
+		bucket := bfs.WithRetry(flaky, policy)
+
+		if _, err := bucket.Head(t.Context(), "a.txt"); !errors.Is(err, errFlaky) {
+			t.Errorf("Expected %v, got %v", errFlaky, err)
+		}
+		if exp, got := map[string]int{"head": 3}, flaky.calls; !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("skips permanent errors", func(t *testing.T) {
+		flaky := &flakyBucket{Bucket: bfs.NewInMem()}
+		bucket := bfs.WithRetry(flaky, policy)
+
+		if _, err := bucket.Head(t.Context(), "missing.txt"); !errors.Is(err, bfs.ErrNotFound) {
+			t.Errorf("Expected %v, got %v", bfs.ErrNotFound, err)
+		}
+		if exp, got := map[string]int{"head": 1}, flaky.calls; !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("replays commits", func(t *testing.T) {
+		ctx := t.Context()
+		base := bfs.NewInMem()
+		commits := 0
+		bucket := bfs.WithRetry(bfs.Wrap(base, bfs.Middleware{
+			Commit: func(_ context.Context, _ string, next func() error) error {
+				if commits++; commits < 3 {
+					return errFlaky
+				}
+				return next()
+			},
+		}), policy)
+
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := map[string]int64{"a.txt": 4}, base.ObjectSizes(); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("restarts listings", func(t *testing.T) {
+		ctx := t.Context()
+		base := bfs.NewInMem()
+		for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+			if err := bfs.WriteObject(ctx, base, name, []byte("data"), nil); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+		}
+
+		globs := 0
+		bucket := bfs.WithRetry(bfs.Wrap(base, bfs.Middleware{
+			Glob: func(ctx context.Context, pattern string, next bfs.GlobFunc) (bfs.Iterator, error) {
+				iter, err := next(ctx, pattern)
+				if globs++; globs == 1 && err == nil {
+					iter = &failingIterator{Iterator: iter, after: 2, err: errFlaky}
+				}
+				return iter, err
+			},
+		}), policy)
+
+		iter, err := bucket.Glob(ctx, "*")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		defer iter.Close()
+
+		var names []string
+		for iter.Next() {
+			names = append(names, iter.Name())
+		}
+		if err := iter.Error(); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp := []string{"a.txt", "b.txt", "c.txt"}; !reflect.DeepEqual(exp, names) {
+			t.Errorf("Expected %v, got %v", exp, names)
+		}
+	})
+
+	t.Run("restarts listings after removals", func(t *testing.T) {
+		ctx := t.Context()
+		base := bfs.NewInMem()
+		for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+			if err := bfs.WriteObject(ctx, base, name, []byte("data"), nil); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+		}
+
+		globs := 0
+		bucket := bfs.WithRetry(bfs.Wrap(base, bfs.Middleware{
+			Glob: func(ctx context.Context, pattern string, next bfs.GlobFunc) (bfs.Iterator, error) {
+				if globs++; globs == 2 {
+					// remove the last returned entry before the restart
+					if err := base.Remove(ctx, "b.txt"); err != nil {
+						return nil, err
+					}
+				}
+				iter, err := next(ctx, pattern)
+				if globs == 1 && err == nil {
+					iter = &failingIterator{Iterator: iter, after: 2, err: errFlaky}
+				}
+				return iter, err
+			},
+		}), policy)
+
+		iter, err := bucket.Glob(ctx, "*")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		defer iter.Close()
+
+		var names []string
+		for iter.Next() {
+			names = append(names, iter.Name())
+		}
+		if err := iter.Error(); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp := []string{"a.txt", "b.txt", "c.txt", "d.txt"}; !reflect.DeepEqual(exp, names) {
+			t.Errorf("Expected %v, got %v", exp, names)
+		}
+	})
+
+	t.Run("resolves", func(t *testing.T) {
+		bucket, err := bfs.Connect(t.Context(), "mem://?retry_attempts=5&retry_backoff=10ms")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if _, ok := bucket.(*bfs.InMem); ok {
+			t.Errorf("Expected bucket to be wrapped")
+		}
+
+		if _, err := bfs.Connect(t.Context(), "mem://?retry_attempts=x"); err == nil {
+			t.Errorf("Expected error")
+		}
+	})
+}
+
+// flakyBucket fails the first N calls to Head, Remove and Create.
+type flakyBucket struct {
+	bfs.Bucket
+
+	failures int
+	calls    map[string]int
+	err      error
+}
+
+func (b *flakyBucket) fail(op string) error {
+	if b.calls == nil {
+		b.calls = make(map[string]int)
+	}
+	if b.calls[op]++; b.calls[op] <= b.failures {
+		return b.err
+	}
+	return nil
+}
+
+func (b *flakyBucket) Head(ctx context.Context, name string) (*bfs.MetaInfo, error) {
+	if err := b.fail("head"); err != nil {
+		return nil, err
+	}
+	return b.Bucket.Head(ctx, name)
+}
+
+func (b *flakyBucket) Remove(ctx context.Context, name string) error {
+	if err := b.fail("remove"); err != nil {
+		return err
+	}
+	return b.Bucket.Remove(ctx, name)
+}
+
+func (b *flakyBucket) Create(ctx context.Context, name string, opts *bfs.WriteOptions) (bfs.Writer, error) {
+	if err := b.fail("create"); err != nil {
+		return nil, err
+	}
+	return b.Bucket.Create(ctx, name, opts)
+}
+
+// failingIterator fails after N entries.
+type failingIterator struct {
+	bfs.Iterator
+
+	after int
+	err   error
+}
+
+func (i *failingIterator) Next() bool {
+	if i.after == 0 {
+		return false
+	}
+	i.after--
+	return i.Iterator.Next()
+}
+
+func (i *failingIterator) Error() error {
+	if i.after == 0 {
+		return i.err
+	}
+	return i.Iterator.Error()
+}
+
+func TestDefaultRetryable(t *testing.T) {
+	for _, tc := range []struct {
+		err error
+		exp bool
+	}{
+		{nil, false},
+		{errors.New("transient"), true},
+		{bfs.ErrNotFound, false},
+		{bfs.ErrExists, false},
+		{bfs.ErrReadOnly, false},
+		{bfs.ErrInvalidName, false},
+		{bfs.ErrQuotaExceeded, false},
+		{fmt.Errorf("wrapped: %w", bfs.ErrExists), false},
+		{errors.ErrUnsupported, false},
+		{os.ErrPermission, false},
+		{context.Canceled, false},
+	} {
+		if got := bfs.DefaultRetryable(tc.err); tc.exp != got {
+			t.Errorf("Expected %v for %v, got %v", tc.exp, tc.err, got)
+		}
+	}
+}
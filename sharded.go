@@ -0,0 +1,228 @@
+package bfs
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Sharded is a bucket which distributes objects across multiple shards
+// using rendezvous (highest random weight) hashing. Each object is stored on
+// exactly one shard. When shards are added or removed, only the objects
+// owned by these shards need to move, see Rebalance.
+type Sharded struct {
+	ids    []string
+	shards []Bucket
+}
+
+// NewSharded inits a sharded bucket. Shards are identified by their keys,
+// which must remain stable across restarts and deployments, as they
+// determine the placement of objects.
+func NewSharded(shards map[string]Bucket) (*Sharded, error) {
+	if len(shards) == 0 {
+		return nil, errors.New("bfs: at least one shard is required")
+	}
+
+	b := &Sharded{
+		ids:    make([]string, 0, len(shards)),
+		shards: make([]Bucket, 0, len(shards)),
+	}
+	for _, id := range slices.Sorted(maps.Keys(shards)) {
+		b.ids = append(b.ids, id)
+		b.shards = append(b.shards, shards[id])
+	}
+	return b, nil
+}
+
+// ShardFor returns the ID of the shard which owns the object name.
+func (b *Sharded) ShardFor(name string) string {
+	return b.ids[b.owner(name)]
+}
+
+// Glob implements Bucket. Shards are queried concurrently, results are
+// merged and sorted by name.
+func (b *Sharded) Glob(ctx context.Context, pattern string) (Iterator, error) {
+	results := make([][]*inMemObject, len(b.shards))
+	errs := make([]error, len(b.shards))
+
+	var wg sync.WaitGroup
+	for i, shard := range b.shards {
+		wg.Go(func() { results[i], errs[i] = globEntries(ctx, shard, pattern) })
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	entries := slices.Concat(results...)
+	slices.SortFunc(entries, func(a, b *inMemObject) int {
+		return strings.Compare(a.info.Name, b.info.Name)
+	})
+	return &inMemIterator{entries: entries, pos: -1}, nil
+}
+
+// Head implements Bucket.
+func (b *Sharded) Head(ctx context.Context, name string) (*MetaInfo, error) {
+	return b.shards[b.owner(name)].Head(ctx, name)
+}
+
+// Open implements Bucket.
+func (b *Sharded) Open(ctx context.Context, name string) (Reader, error) {
+	return b.shards[b.owner(name)].Open(ctx, name)
+}
+
+// Create implements Bucket.
+func (b *Sharded) Create(ctx context.Context, name string, opts *WriteOptions) (Writer, error) {
+	return b.shards[b.owner(name)].Create(ctx, name, opts)
+}
+
+// Remove implements Bucket.
+func (b *Sharded) Remove(ctx context.Context, name string) error {
+	return b.shards[b.owner(name)].Remove(ctx, name)
+}
+
+// Copy implements Bucket extension. Objects can only be copied natively
+// if source and destination are owned by the same shard.
+func (b *Sharded) Copy(ctx context.Context, src, dst string) error {
+	shard := b.shards[b.owner(src)]
+	if copier, ok := shard.(supportsCopy); ok && b.owner(src) == b.owner(dst) {
+		return copier.Copy(ctx, src, dst)
+	}
+	return errors.ErrUnsupported
+}
+
+// RemoveAll implements Bucket extension.
+func (b *Sharded) RemoveAll(ctx context.Context, pattern string) error {
+	errs := make([]error, len(b.shards))
+
+	var wg sync.WaitGroup
+	for i, shard := range b.shards {
+		wg.Go(func() { errs[i] = RemoveAll(ctx, shard, pattern) })
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// Close implements Bucket. It closes all shards.
+func (b *Sharded) Close() error {
+	var err error
+	for _, shard := range b.shards {
+		err = errors.Join(err, shard.Close())
+	}
+	return err
+}
+
+// Rebalance scans all shards and moves objects which are stored on the
+// wrong shard, e.g. after a new shard was added, to their owners. It returns
+// the number of moved objects.
+//
+// Objects are copied to their new owners before they are removed from the
+// old ones, so they may temporarily be listed twice by Glob.
+func (b *Sharded) Rebalance(ctx context.Context) (int, error) {
+	var moved int
+	for i, shard := range b.shards {
+		entries, err := globEntries(ctx, shard, "**")
+		if err != nil {
+			return moved, err
+		}
+
+		for _, entry := range entries {
+			name := entry.info.Name
+			if owner := b.owner(name); owner != i {
+				if err := moveObject(ctx, shard, b.shards[owner], name); err != nil {
+					return moved, err
+				}
+				moved++
+			}
+		}
+	}
+	return moved, nil
+}
+
+// owner returns the index of the shard which owns the name.
+func (b *Sharded) owner(name string) int {
+	var pos int
+	var top uint64
+	for i, id := range b.ids {
+		if w := shardWeight(id, name); i == 0 || w > top {
+			pos, top = i, w
+		}
+	}
+	return pos
+}
+
+// shardWeight calculates the rendezvous weight of a name on a shard.
+func shardWeight(id, name string) uint64 {
+	h := fnv.New64a()
+	_, _ = io.WriteString(h, id)
+	_, _ = h.Write([]byte{0})
+	_, _ = io.WriteString(h, name)
+
+	// finalize with splitmix64 for better distribution
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// globEntries collects the entries of a bucket matching a pattern.
+func globEntries(ctx context.Context, bucket Bucket, pattern string) ([]*inMemObject, error) {
+	iter, err := bucket.Glob(ctx, pattern)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var entries []*inMemObject
+	for iter.Next() {
+		entries = append(entries, &inMemObject{info: MetaInfo{
+			Name:    iter.Name(),
+			Size:    iter.Size(),
+			ModTime: iter.ModTime(),
+		}})
+	}
+	return entries, iter.Error()
+}
+
+// moveObject moves an object between buckets, preserving content type,
+// metadata and, where supported, modification time.
+func moveObject(ctx context.Context, src, dst Bucket, name string) error {
+	info, err := src.Head(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	r, err := src.Open(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := dst.Create(ctx, name, &WriteOptions{
+		ContentType: info.ContentType,
+		Metadata:    info.Metadata,
+		ModTime:     info.ModTime,
+	})
+	if err != nil {
+		return err
+	}
+	defer w.Discard()
+
+	if _, err := io.Copy(w, r); err != nil {
+		return err
+	}
+	if err := w.Commit(); err != nil {
+		return err
+	}
+	return src.Remove(ctx, name)
+}
@@ -0,0 +1,136 @@
+package bfs_test
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/bsm/bfs"
+	"github.com/bsm/bfs/bfstest"
+)
+
+func TestSharded(t *testing.T) {
+	t.Run("lint", func(t *testing.T) {
+		bucket, err := bfs.NewSharded(map[string]bfs.Bucket{
+			"a": bfs.NewInMem(),
+			"b": bfs.NewInMem(),
+			"c": bfs.NewInMem(),
+		})
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		bfstest.Common(t, bucket, bfstest.Supports{Metadata: true})
+	})
+
+	t.Run("validates", func(t *testing.T) {
+		if _, err := bfs.NewSharded(nil); err == nil {
+			t.Errorf("Expected error")
+		}
+	})
+
+	t.Run("distributes objects", func(t *testing.T) {
+		ctx := t.Context()
+		shards := map[string]*bfs.InMem{"a": bfs.NewInMem(), "b": bfs.NewInMem(), "c": bfs.NewInMem()}
+		bucket, err := bfs.NewSharded(map[string]bfs.Bucket{"a": shards["a"], "b": shards["b"], "c": shards["c"]})
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		var names []string
+		for i := range 300 {
+			name := fmt.Sprintf("dir/%03d.txt", i)
+			if err := bfs.WriteObject(ctx, bucket, name, []byte("data"), nil); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+			names = append(names, name)
+		}
+
+		for id, shard := range shards {
+			sizes := shard.ObjectSizes()
+			if n := len(sizes); n < 70 || n > 130 {
+				t.Errorf("Expected shard %s to hold ~100 objects, got %d", id, n)
+			}
+			for name := range sizes {
+				if exp, got := id, bucket.ShardFor(name); exp != got {
+					t.Errorf("Expected %v, got %v", exp, got)
+				}
+			}
+		}
+
+		iter, err := bucket.Glob(ctx, "dir/*")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		defer iter.Close()
+
+		var listed []string
+		for iter.Next() {
+			listed = append(listed, iter.Name())
+		}
+		if err := iter.Error(); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if !slices.Equal(names, listed) {
+			t.Errorf("Expected %v, got %v", names, listed)
+		}
+	})
+
+	t.Run("rebalances", func(t *testing.T) {
+		ctx := t.Context()
+		a, b, c := bfs.NewInMem(), bfs.NewInMem(), bfs.NewInMem()
+		before, err := bfs.NewSharded(map[string]bfs.Bucket{"a": a, "b": b})
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		for i := range 100 {
+			name := fmt.Sprintf("%03d.txt", i)
+			opts := &bfs.WriteOptions{ContentType: "text/plain", Metadata: bfs.Metadata{"Num": fmt.Sprint(i)}}
+			if err := bfs.WriteObject(ctx, before, name, []byte("data"), opts); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+		}
+
+		after, err := bfs.NewSharded(map[string]bfs.Bucket{"a": a, "b": b, "c": c})
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		moved, err := after.Rebalance(ctx)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := len(c.ObjectSizes()), moved; exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if moved < 20 || moved > 50 {
+			t.Errorf("Expected ~33 objects to move, got %d", moved)
+		}
+		if exp, got := 100, len(a.ObjectSizes())+len(b.ObjectSizes())+len(c.ObjectSizes()); exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		for name := range c.ObjectSizes() {
+			info, err := after.Head(ctx, name)
+			if err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+			if exp, got := "text/plain", info.ContentType; exp != got {
+				t.Errorf("Expected %v, got %v", exp, got)
+			}
+			num, _ := strconv.Atoi(name[:3])
+			if exp, got := (bfs.Metadata{"Num": strconv.Itoa(num)}), info.Metadata; !reflect.DeepEqual(exp, got) {
+				t.Errorf("Expected %v, got %v", exp, got)
+			}
+		}
+
+		moved, err = after.Rebalance(ctx)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := 0, moved; exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+}
@@ -0,0 +1,297 @@
+package bfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ErrInvalidName is returned when an object name or pattern
+// is not valid, e.g. if it points outside of a sub-bucket.
+var ErrInvalidName = errors.New("bfs: invalid name")
+
+// Sub returns a bucket which is confined to the given prefix of the parent
+// bucket. Names are translated transparently, names which point outside the
+// prefix (e.g. "../secret.txt") are rejected with ErrInvalidName.
+//
+// Closing the sub-bucket closes the parent.
+func Sub(bucket Bucket, prefix string) Bucket {
+	prefix = strings.Trim(path.Clean("/"+prefix), "/")
+	if prefix == "" {
+		return bucket
+	}
+
+	// unwrap nested sub-buckets
+	if s, ok := bucket.(*subBucket); ok {
+		return &subBucket{Bucket: s.Bucket, prefix: s.prefix + prefix + "/"}
+	}
+	return &subBucket{Bucket: bucket, prefix: prefix + "/"}
+}
+
+type subBucket struct {
+	Bucket
+
+	prefix string // with trailing slash
+}
+
+// Glob implements Bucket.
+func (b *subBucket) Glob(ctx context.Context, pattern string) (Iterator, error) {
+	if pattern == "" {
+		return &inMemIterator{pos: -1}, nil
+	}
+	full, err := b.withPrefixPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := b.Bucket.Glob(ctx, full)
+	if err != nil {
+		return nil, err
+	}
+	return &subIterator{Iterator: iter, prefix: b.prefix}, nil
+}
+
+// Head implements Bucket.
+func (b *subBucket) Head(ctx context.Context, name string) (*MetaInfo, error) {
+	full, err := b.withPrefix(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := b.Bucket.Head(ctx, full)
+	if err != nil {
+		return nil, err
+	}
+
+	dup := *info
+	dup.Name = strings.TrimPrefix(info.Name, b.prefix)
+	return &dup, nil
+}
+
+// Open implements Bucket.
+func (b *subBucket) Open(ctx context.Context, name string) (Reader, error) {
+	full, err := b.withPrefix(name)
+	if err != nil {
+		return nil, err
+	}
+	return b.Bucket.Open(ctx, full)
+}
+
+// Create implements Bucket.
+func (b *subBucket) Create(ctx context.Context, name string, opts *WriteOptions) (Writer, error) {
+	full, err := b.withPrefix(name)
+	if err != nil {
+		return nil, err
+	}
+	return b.Bucket.Create(ctx, full, opts)
+}
+
+// CreateNew implements Bucket extension.
+func (b *subBucket) CreateNew(ctx context.Context, name string, opts *WriteOptions) (Writer, error) {
+	exclusive, ok := b.Bucket.(supportsCreateNew)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+
+	full, err := b.withPrefix(name)
+	if err != nil {
+		return nil, err
+	}
+	return exclusive.CreateNew(ctx, full, opts)
+}
+
+// Remove implements Bucket.
+func (b *subBucket) Remove(ctx context.Context, name string) error {
+	full, err := b.withPrefix(name)
+	if err != nil {
+		return err
+	}
+	return b.Bucket.Remove(ctx, full)
+}
+
+// Copy implements Bucket extension.
+func (b *subBucket) Copy(ctx context.Context, src, dst string) error {
+	copier, ok := b.Bucket.(supportsCopy)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+
+	fullSrc, err := b.withPrefix(src)
+	if err != nil {
+		return err
+	}
+	fullDst, err := b.withPrefix(dst)
+	if err != nil {
+		return err
+	}
+	return copier.Copy(ctx, fullSrc, fullDst)
+}
+
+// RemoveAll implements Bucket extension.
+func (b *subBucket) RemoveAll(ctx context.Context, pattern string) error {
+	remover, ok := b.Bucket.(supportsRemoveAll)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	if pattern == "" {
+		return nil
+	}
+
+	full, err := b.withPrefixPattern(pattern)
+	if err != nil {
+		return err
+	}
+	return remover.RemoveAll(ctx, full)
+}
+
+// ListVersions implements Versioned.
+func (b *subBucket) ListVersions(ctx context.Context, name string) ([]*VersionInfo, error) {
+	versioned, ok := b.Bucket.(Versioned)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+
+	full, err := b.withPrefix(name)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := versioned.ListVersions(ctx, full)
+	if err != nil {
+		return nil, err
+	}
+
+	dups := make([]*VersionInfo, 0, len(versions))
+	for _, v := range versions {
+		dup := *v
+		dup.Name = strings.TrimPrefix(v.Name, b.prefix)
+		dups = append(dups, &dup)
+	}
+	return dups, nil
+}
+
+// HeadVersion implements Versioned.
+func (b *subBucket) HeadVersion(ctx context.Context, name, versionID string) (*MetaInfo, error) {
+	versioned, ok := b.Bucket.(Versioned)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+
+	full, err := b.withPrefix(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := versioned.HeadVersion(ctx, full, versionID)
+	if err != nil {
+		return nil, err
+	}
+
+	dup := *info
+	dup.Name = strings.TrimPrefix(info.Name, b.prefix)
+	return &dup, nil
+}
+
+// OpenVersion implements Versioned.
+func (b *subBucket) OpenVersion(ctx context.Context, name, versionID string) (Reader, error) {
+	versioned, ok := b.Bucket.(Versioned)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+
+	full, err := b.withPrefix(name)
+	if err != nil {
+		return nil, err
+	}
+	return versioned.OpenVersion(ctx, full, versionID)
+}
+
+// RemoveVersion implements Versioned.
+func (b *subBucket) RemoveVersion(ctx context.Context, name, versionID string) error {
+	versioned, ok := b.Bucket.(Versioned)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+
+	full, err := b.withPrefix(name)
+	if err != nil {
+		return err
+	}
+	return versioned.RemoveVersion(ctx, full, versionID)
+}
+
+// RestoreVersion implements Versioned extension.
+func (b *subBucket) RestoreVersion(ctx context.Context, name, versionID string) error {
+	restorer, ok := b.Bucket.(supportsRestoreVersion)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+
+	full, err := b.withPrefix(name)
+	if err != nil {
+		return err
+	}
+	return restorer.RestoreVersion(ctx, full, versionID)
+}
+
+// Notify implements Bucket extension.
+func (b *subBucket) Notify(ctx context.Context, pattern string) (<-chan struct{}, error) {
+	notifier, ok := b.Bucket.(supportsNotify)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+
+	full, err := b.withPrefixPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return notifier.Notify(ctx, full)
+}
+
+// withPrefix returns the full name of an object.
+func (b *subBucket) withPrefix(name string) (string, error) {
+	clean := path.Clean(strings.TrimLeft(name, "/"))
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("%w %q", ErrInvalidName, name)
+	}
+	return b.prefix + clean, nil
+}
+
+// withPrefixPattern returns the full pattern.
+func (b *subBucket) withPrefixPattern(pattern string) (string, error) {
+	pattern = strings.TrimLeft(pattern, "/")
+	for _, seg := range strings.Split(pattern, "/") {
+		if seg == ".." {
+			return "", fmt.Errorf("%w %q", ErrInvalidName, pattern)
+		}
+	}
+	return escapeGlob(b.prefix) + pattern, nil
+}
+
+// escapeGlob escapes glob meta characters.
+func escapeGlob(s string) string {
+	if !strings.ContainsAny(s, `*?[]{}\`) {
+		return s
+	}
+
+	var sb strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(`*?[]{}\`, c) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+type subIterator struct {
+	Iterator
+
+	prefix string
+}
+
+func (i *subIterator) Name() string {
+	return strings.TrimPrefix(i.Iterator.Name(), i.prefix)
+}
@@ -0,0 +1,83 @@
+package bfs_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bsm/bfs"
+	"github.com/bsm/bfs/bfstest"
+)
+
+func TestSub(t *testing.T) {
+	t.Run("lint", func(t *testing.T) {
+		bucket := bfs.Sub(bfs.NewInMem(), "x/y")
+		bfstest.Common(t, bucket, bfstest.Supports{Metadata: true})
+	})
+
+	t.Run("lint special prefix", func(t *testing.T) {
+		bucket := bfs.Sub(bfs.Sub(bfs.NewInMem(), "/[x]/"), "{y}*")
+		bfstest.Common(t, bucket, bfstest.Supports{Metadata: true})
+	})
+
+	t.Run("translates names", func(t *testing.T) {
+		ctx := t.Context()
+		base := bfs.NewInMem()
+		bucket := bfs.Sub(base, "/x/y/")
+
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bfs.WriteObject(ctx, bucket, "/b/../c/d.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bfs.WriteObject(ctx, base, "x/z.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bfs.CopyObject(ctx, bucket, "a.txt", "e.txt", nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		if exp, got := map[string]int64{
+			"x/y/a.txt":   4,
+			"x/y/c/d.txt": 4,
+			"x/y/e.txt":   4,
+			"x/z.txt":     4,
+		}, base.ObjectSizes(); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		info, err := bucket.Head(ctx, "c/d.txt")
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := "c/d.txt", info.Name; exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+
+		if err := bfs.RemoveAll(ctx, bucket, "**"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := map[string]int64{"x/z.txt": 4}, base.ObjectSizes(); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("rejects traversal", func(t *testing.T) {
+		ctx := t.Context()
+		base := bfs.NewInMem()
+		bucket := bfs.Sub(base, "x/y")
+
+		for _, name := range []string{"../z.txt", "/../z.txt", "a/../../z.txt", ".."} {
+			if _, err := bucket.Create(ctx, name, nil); !errors.Is(err, bfs.ErrInvalidName) {
+				t.Errorf("Expected %v, got %v", bfs.ErrInvalidName, err)
+			}
+			if _, err := bucket.Head(ctx, name); !errors.Is(err, bfs.ErrInvalidName) {
+				t.Errorf("Expected %v, got %v", bfs.ErrInvalidName, err)
+			}
+		}
+		if _, err := bucket.Glob(ctx, "../*"); !errors.Is(err, bfs.ErrInvalidName) {
+			t.Errorf("Expected %v, got %v", bfs.ErrInvalidName, err)
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package bfs
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// VersionInfo contains meta information about a specific object version.
+type VersionInfo struct {
+	MetaInfo
+
+	// VersionID identifies the version, e.g. an S3 version ID or a GCS
+	// generation.
+	VersionID string
+	// IsLatest is true for the current version of an object.
+	IsLatest bool
+	// IsDeleteMarker is true if the version marks the removal of an object.
+	// Delete markers have no data.
+	IsDeleteMarker bool
+}
+
+// Versioned is an optional extension which is implemented by buckets
+// with object versioning. Implementations may return errors.ErrUnsupported
+// if versioning is not enabled.
+type Versioned interface {
+	Bucket
+
+	// ListVersions lists all versions of an object, newest first. It returns
+	// ErrNotFound if no versions exist.
+	ListVersions(ctx context.Context, name string) ([]*VersionInfo, error)
+
+	// HeadVersion returns the meta info of a specific object version.
+	HeadVersion(ctx context.Context, name, versionID string) (*MetaInfo, error)
+
+	// OpenVersion opens a specific object version for reading.
+	OpenVersion(ctx context.Context, name, versionID string) (Reader, error)
+
+	// RemoveVersion permanently removes a specific object version.
+	RemoveVersion(ctx context.Context, name, versionID string) error
+}
+
+// supportsRestoreVersion is an optional extension for restoring versions
+// natively. Implementations may return errors.ErrUnsupported to trigger the
+// generic fallback.
+type supportsRestoreVersion interface {
+	RestoreVersion(context.Context, string, string) error
+}
+
+// RestoreVersion restores a specific version as the current version of an
+// object, content type and metadata are preserved.
+func RestoreVersion(ctx context.Context, bucket Versioned, name, versionID string) error {
+	if b, ok := bucket.(supportsRestoreVersion); ok {
+		if err := b.RestoreVersion(ctx, name, versionID); !errors.Is(err, errors.ErrUnsupported) {
+			return err
+		}
+	}
+
+	info, err := bucket.HeadVersion(ctx, name, versionID)
+	if err != nil {
+		return err
+	}
+
+	r, err := bucket.OpenVersion(ctx, name, versionID)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := bucket.Create(ctx, name, &WriteOptions{
+		ContentType: info.ContentType,
+		Metadata:    info.Metadata,
+	})
+	if err != nil {
+		return err
+	}
+	defer w.Discard()
+
+	if _, err := io.Copy(w, r); err != nil {
+		return err
+	}
+	return w.Commit()
+}
@@ -0,0 +1,222 @@
+package bfs
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// EventType is the type of a watch event.
+type EventType uint8
+
+// Watch event types.
+const (
+	Created EventType = iota + 1
+	Modified
+	Removed
+)
+
+// String returns the event type name.
+func (t EventType) String() string {
+	switch t {
+	case Created:
+		return "created"
+	case Modified:
+		return "modified"
+	case Removed:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// Event is a change event emitted by Watch.
+type Event struct {
+	Type EventType
+	Name string
+	// Size and ModTime describe the object, or the last known state of
+	// the object for Removed events.
+	Size    int64
+	ModTime time.Time
+}
+
+// WatchConfig contains optional Watch configuration.
+type WatchConfig struct {
+	// Interval is the interval between listings. Buckets with native change
+	// notifications are additionally listed whenever notified.
+	// Default: 1m
+	Interval time.Duration
+	// EmitExisting emits Created events for all objects which exist when
+	// watching starts. By default, the initial listing is only used as a
+	// baseline.
+	EmitExisting bool
+	// OnError is called when a listing fails. Failed listings are retried on
+	// the next interval or notification.
+	// Default: errors are ignored
+	OnError func(error)
+}
+
+func (c *WatchConfig) norm() *WatchConfig {
+	if c.Interval <= 0 {
+		c.Interval = time.Minute
+	}
+	return c
+}
+
+// supportsNotify is an optional extension for native change notifications.
+// The returned channel must receive a value whenever objects matching the
+// pattern may have changed and be closed when the context is done.
+// Implementations may return errors.ErrUnsupported to fall back on polling.
+type supportsNotify interface {
+	Notify(context.Context, string) (<-chan struct{}, error)
+}
+
+// Watch watches objects matching a glob pattern and emits events by diffing
+// successive listings on name, size and modification time. The returned
+// channel is closed when the context is done.
+func Watch(ctx context.Context, bucket Bucket, pattern string, interval time.Duration) (<-chan Event, error) {
+	return WatchWithConfig(ctx, bucket, pattern, &WatchConfig{Interval: interval})
+}
+
+// WatchWithConfig is like Watch but with custom configuration.
+func WatchWithConfig(ctx context.Context, bucket Bucket, pattern string, cfg *WatchConfig) (<-chan Event, error) {
+	config := new(WatchConfig)
+	if cfg != nil {
+		*config = *cfg
+	}
+	config.norm()
+
+	// stop notifications when the watcher stops or fails to start
+	ctx, cancel := context.WithCancel(ctx)
+
+	var notify <-chan struct{}
+	if b, ok := bucket.(supportsNotify); ok {
+		ch, err := b.Notify(ctx, pattern)
+		if err != nil && !errors.Is(err, errors.ErrUnsupported) {
+			cancel()
+			return nil, err
+		}
+		notify = ch
+	}
+
+	snap, err := watchListing(ctx, bucket, pattern)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	w := &watcher{
+		bucket:  bucket,
+		pattern: pattern,
+		config:  config,
+		events:  make(chan Event),
+		snap:    snap,
+	}
+	go func() {
+		defer cancel()
+		w.loop(ctx, notify)
+	}()
+	return w.events, nil
+}
+
+type watcher struct {
+	bucket  Bucket
+	pattern string
+	config  *WatchConfig
+	events  chan Event
+	snap    map[string]Event
+}
+
+func (w *watcher) loop(ctx context.Context, notify <-chan struct{}) {
+	defer close(w.events)
+
+	if w.config.EmitExisting {
+		if !w.emit(ctx, diffListings(nil, w.snap)) {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case _, ok := <-notify:
+			if !ok {
+				notify = nil
+				continue
+			}
+		}
+
+		snap, err := watchListing(ctx, w.bucket, w.pattern)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if w.config.OnError != nil {
+				w.config.OnError(err)
+			}
+			continue
+		}
+
+		events := diffListings(w.snap, snap)
+		w.snap = snap
+		if !w.emit(ctx, events) {
+			return
+		}
+	}
+}
+
+func (w *watcher) emit(ctx context.Context, events []Event) bool {
+	for _, e := range events {
+		select {
+		case w.events <- e:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// watchListing lists all objects matching pattern.
+func watchListing(ctx context.Context, bucket Bucket, pattern string) (map[string]Event, error) {
+	iter, err := bucket.Glob(ctx, pattern)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	snap := make(map[string]Event)
+	for iter.Next() {
+		snap[iter.Name()] = Event{Name: iter.Name(), Size: iter.Size(), ModTime: iter.ModTime()}
+	}
+	return snap, iter.Error()
+}
+
+// diffListings returns the events between two listings, sorted by name.
+func diffListings(prev, next map[string]Event) []Event {
+	var events []Event
+	for name, e := range next {
+		if p, ok := prev[name]; !ok {
+			e.Type = Created
+			events = append(events, e)
+		} else if p.Size != e.Size || !p.ModTime.Equal(e.ModTime) {
+			e.Type = Modified
+			events = append(events, e)
+		}
+	}
+	for name, e := range prev {
+		if _, ok := next[name]; !ok {
+			e.Type = Removed
+			events = append(events, e)
+		}
+	}
+	slices.SortFunc(events, func(a, b Event) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return events
+}
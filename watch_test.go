@@ -0,0 +1,204 @@
+package bfs_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/bsm/bfs"
+	"github.com/bsm/bfs/bfstest"
+)
+
+func TestWatch(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	newBucket := func(t *testing.T) *bfs.InMem {
+		t.Helper()
+
+		bucket := bfs.NewInMemWithConfig(&bfs.InMemConfig{
+			Clock: func() time.Time { return now },
+		})
+		for _, name := range []string{"a.txt", "b.txt", "c.csv"} {
+			if err := bfs.WriteObject(t.Context(), bucket, name, []byte("data"), nil); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+		}
+		return bucket
+	}
+
+	receive := func(t *testing.T, events <-chan bfs.Event, n int) []string {
+		t.Helper()
+
+		var got []string
+		for range n {
+			select {
+			case e := <-events:
+				got = append(got, e.Type.String()+" "+e.Name)
+			case <-time.After(time.Second):
+				t.Fatalf("Timeout waiting for events, got %v", got)
+			}
+		}
+		return got
+	}
+
+	t.Run("emits changes", func(t *testing.T) {
+		ctx := t.Context()
+		bucket := newBucket(t)
+
+		events, err := bfs.Watch(ctx, bucket, "*.txt", 10*time.Millisecond)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		if err := bfs.WriteObject(ctx, bucket, "d.txt", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bfs.WriteObject(ctx, bucket, "a.txt", []byte("changed"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bfs.WriteObject(ctx, bucket, "e.csv", []byte("data"), nil); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if err := bucket.Remove(ctx, "b.txt"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		// changes may be detected across multiple listings
+		got := receive(t, events, 3)
+		slices.Sort(got)
+		if exp := []string{
+			"created d.txt",
+			"modified a.txt",
+			"removed b.txt",
+		}; !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("emits existing", func(t *testing.T) {
+		ctx := t.Context()
+		bucket := newBucket(t)
+
+		events, err := bfs.WatchWithConfig(ctx, bucket, "*.txt", &bfs.WatchConfig{
+			Interval:     time.Hour,
+			EmitExisting: true,
+		})
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		if exp, got := []string{
+			"created a.txt",
+			"created b.txt",
+		}, receive(t, events, 2); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+
+	t.Run("closes on cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		events, err := bfs.Watch(ctx, newBucket(t), "**", time.Hour)
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		cancel()
+		select {
+		case _, ok := <-events:
+			if ok {
+				t.Error("Expected channel to be closed")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for close")
+		}
+	})
+
+	t.Run("stops notifications on failure", func(t *testing.T) {
+		errFlaky := errors.New("flaky")
+		bucket := &notifyingBucket{Bucket: bfs.Wrap(newBucket(t), bfs.Middleware{
+			Glob: func(context.Context, string, bfs.GlobFunc) (bfs.Iterator, error) {
+				return nil, errFlaky
+			},
+		})}
+		if _, err := bfs.Watch(t.Context(), bucket, "**", time.Hour); err != errFlaky {
+			t.Errorf("Expected %v, got %v", errFlaky, err)
+		}
+		if err := bucket.ctx.Err(); err != context.Canceled {
+			t.Errorf("Expected %v, got %v", context.Canceled, err)
+		}
+	})
+
+	for _, tc := range []struct {
+		name    string
+		wrap    func(bfs.Bucket) bfs.Bucket
+		pattern string
+	}{
+		{"wrapped", func(b bfs.Bucket) bfs.Bucket { return bfs.Wrap(b, bfs.Middleware{}) }, "*.txt"},
+		{"retry", func(b bfs.Bucket) bfs.Bucket { return bfs.WithRetry(b, nil) }, "*.txt"},
+		{"sub", func(b bfs.Bucket) bfs.Bucket { return bfs.Sub(b, "x") }, "x/*.txt"},
+		{"recorder", func(b bfs.Bucket) bfs.Bucket { return bfstest.NewRecorder(b) }, "*.txt"},
+	} {
+		t.Run(tc.name+" forwards notifications", func(t *testing.T) {
+			bucket := &notifyingBucket{Bucket: newBucket(t)}
+			if _, err := bfs.Watch(t.Context(), tc.wrap(bucket), "*.txt", time.Hour); err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+			if bucket.ctx == nil {
+				t.Fatal("Expected Notify to be called")
+			}
+			if exp, got := tc.pattern, bucket.pattern; exp != got {
+				t.Errorf("Expected %v, got %v", exp, got)
+			}
+		})
+	}
+
+	t.Run("reports errors", func(t *testing.T) {
+		errFlaky := errors.New("flaky")
+		bucket := newBucket(t)
+		calls := 0
+		flaky := bfs.Wrap(bucket, bfs.Middleware{
+			Glob: func(ctx context.Context, pattern string, next bfs.GlobFunc) (bfs.Iterator, error) {
+				if calls++; calls == 2 {
+					return nil, errFlaky
+				}
+				return next(ctx, pattern)
+			},
+		})
+
+		errs := make(chan error, 1)
+		events, err := bfs.WatchWithConfig(t.Context(), flaky, "*.txt", &bfs.WatchConfig{
+			Interval: 10 * time.Millisecond,
+			OnError:  func(err error) { errs <- err },
+		})
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+
+		if err := bucket.Remove(t.Context(), "a.txt"); err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		if exp, got := []string{"removed a.txt"}, receive(t, events, 1); !reflect.DeepEqual(exp, got) {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+		if exp, got := errFlaky, <-errs; exp != got {
+			t.Errorf("Expected %v, got %v", exp, got)
+		}
+	})
+}
+
+// notifyingBucket supports native notifications and
+// records the context and pattern passed to Notify.
+type notifyingBucket struct {
+	bfs.Bucket
+
+	ctx     context.Context
+	pattern string
+}
+
+func (b *notifyingBucket) Notify(ctx context.Context, pattern string) (<-chan struct{}, error) {
+	b.ctx = ctx
+	b.pattern = pattern
+	return make(chan struct{}), nil
+}